@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// 应用团队通过 pod 注解自行控制探测，与 Prometheus kubernetes SD 的注解用法类似
+const (
+	// 值为 "false" 时不探测该 pod
+	scrapeAnnotation = "health-check.exporter/scrape"
+	// 逗号分隔的 pod 标签名，这些标签会作为额外的标签附加到该 pod 的指标上
+	labelsAnnotation = "health-check.exporter/labels"
+	// 一个 pod 最多追加的注解标签数，超出的标签按名称排序后忽略，避免单个 pod 的注解让序列的标签无限增加
+	maxAnnotationLabels = 5
+)
+
+// pod 是否通过注解关闭了探测
+func scrapeDisabled(pod *coreV1.Pod) bool {
+	return strings.EqualFold(strings.TrimSpace(pod.Annotations[scrapeAnnotation]), "false")
+}
+
+/**
+ * @function: descSpec
+ * @desc: 逐个容器输出的指标的帮助信息和基础标签，用于按注解追加标签后重新构造 Desc
+ */
+type descSpec struct {
+	help   string
+	labels []string
+}
+
+/**
+ * @function: annotationLabels
+ * @desc: 解析 health-check.exporter/labels 注解，返回排序去重后的 pod 标签名和对应的 Prometheus 标签名。
+ *        标签名中的非法字符替换为下划线，与基础标签或 replica 等固定标签重名的标签被忽略，最多返回 maxAnnotationLabels 个
+ */
+func annotationLabels(pod *coreV1.Pod, reserved []string) (keys, names []string) {
+	value := pod.Annotations[labelsAnnotation]
+	if value == "" {
+		return nil, nil
+	}
+	seen := map[string]bool{}
+	for _, r := range reserved {
+		seen[r] = true
+	}
+	var candidates []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Strings(candidates)
+	for _, key := range candidates {
+		if len(keys) == maxAnnotationLabels {
+			break
+		}
+		name := sanitizeLabelName(key)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		keys = append(keys, key)
+		names = append(names, name)
+	}
+	return keys, names
+}
+
+// 把 pod 标签名转换为合法的 Prometheus 标签名，例如 app.kubernetes.io/name 转换为 app_kubernetes_io_name
+func sanitizeLabelName(key string) string {
+	b := []byte(key)
+	for i, ch := range b {
+		if !(ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	name := string(b)
+	if strings.HasPrefix(name, "__") {
+		// 双下划线开头的标签名由 Prometheus 保留
+		name = "label" + name
+	}
+	return name
+}
+
+/**
+ * @function: annotatedDescs
+ * @desc: 按指标名称和追加的标签名缓存 Desc，同一组注解标签的 pod 共用一个 Desc。
+ *        每次抓取结束时清理本次没有用到的 Desc，缓存大小只取决于当前 pod 的注解，不随注解的修改历史增长
+ */
+type annotatedDescs struct {
+	mu    sync.Mutex
+	descs map[string]*prometheus.Desc
+	used  map[string]struct{}
+}
+
+func (d *annotatedDescs) get(key string, build func() *prometheus.Desc) *prometheus.Desc {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.descs == nil {
+		d.descs = map[string]*prometheus.Desc{}
+		d.used = map[string]struct{}{}
+	}
+	d.used[key] = struct{}{}
+	if desc, ok := d.descs[key]; ok {
+		return desc
+	}
+	desc := build()
+	d.descs[key] = desc
+	return desc
+}
+
+// 删除上次清理以来没有用到的 Desc
+func (d *annotatedDescs) reap() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key := range d.descs {
+		if _, ok := d.used[key]; !ok {
+			delete(d.descs, key)
+		}
+	}
+	d.used = map[string]struct{}{}
+}
+
+/**
+ * @function: podMetric
+ * @desc: 构造逐个容器输出的指标，values 为基础标签之后的额外标签值。
+ *        pod 配置了 health-check.exporter/labels 注解时追加对应的 pod 标签，pod 上没有该标签时值为空。
+ *        标签名来自 pod 注解，构造失败时只丢弃这一条样本并计数，不能让一个 pod 的配置影响整个抓取
+ */
+func (c *Metrics) podMetric(name string, value float64, r *probeResult, values ...string) (prometheus.Metric, bool) {
+	desc := c.metrics[name]
+	labelValues := append(c.podLabelValues(r), values...)
+
+	spec := c.descSpecs[name]
+	reserved := append([]string{}, spec.labels...)
+	for label := range c.cfg.constLabels() {
+		reserved = append(reserved, label)
+	}
+	if keys, names := annotationLabels(r.pod, reserved); len(keys) > 0 {
+		desc = c.annotatedDescs.get(name+"\x00"+strings.Join(names, ","), func() *prometheus.Desc {
+			labels := append(append([]string{}, spec.labels...), names...)
+			return c.cfg.newGlobalMetric(name, spec.help, labels)
+		})
+		for _, key := range keys {
+			labelValues = append(labelValues, c.truncateLabel(r.pod.Labels[key]))
+		}
+	}
+	metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	if err != nil {
+		c.annotationLabelErrors.Inc()
+		slog.Warn("Dropping sample with invalid annotation labels", "metric", name, "namespace", r.pod.Namespace, "pod", r.pod.Name, "err", err)
+		return nil, false
+	}
+	return metric, true
+}
+
+// 构造并输出逐个容器的指标，构造失败时跳过
+func (c *Metrics) sendPodMetric(ch chan<- prometheus.Metric, name string, value float64, r *probeResult, values ...string) {
+	if metric, ok := c.podMetric(name, value, r, values...); ok {
+		ch <- metric
+	}
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 注解请求的标签与 replica 固定标签重名时忽略该标签，不能让 Collect panic
+func TestAnnotationLabelsReserveConstLabels(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	pod := newTestPod("a", ip, port)
+	pod.Labels = map[string]string{"replica": "2", "team": "payment"}
+	pod.Annotations = map[string]string{labelsAnnotation: "replica,team"}
+	cfg := DefaultConfig()
+	cfg.InstanceLabel = "exporter-0"
+	c := newTestMetrics(t, cfg, pod)
+
+	up := samples(t, collectMetrics(c), "container_health_check_up")
+	if len(up) != 1 {
+		t.Fatalf("got %d container_health_check_up samples, want 1", len(up))
+	}
+	if got := labelValue(up[0], "replica"); got != "exporter-0" {
+		t.Errorf("replica = %q, want the const label exporter-0", got)
+	}
+	if got := labelValue(up[0], "team"); got != "payment" {
+		t.Errorf("team = %q, want payment", got)
+	}
+}
+
+// 注解关闭探测的 pod 不输出逐容器指标，只计入 reason="annotation"
+func TestScrapeAnnotationDisablesProbe(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	disabled := newTestPod("disabled", ip, port)
+	disabled.Annotations = map[string]string{scrapeAnnotation: "false"}
+	c := newTestMetrics(t, DefaultConfig(), newTestPod("enabled", ip, port), disabled)
+
+	metrics := collectMetrics(c)
+	up := samples(t, metrics, "container_health_check_up")
+	if len(up) != 1 || labelValue(up[0], "pod_name") != "enabled" {
+		t.Fatalf("container_health_check_up = %v, want only the enabled pod", up)
+	}
+	skipped := -1.0
+	for _, m := range samples(t, metrics, "container_health_check_skipped") {
+		if labelValue(m, "reason") == "annotation" {
+			skipped = m.GetGauge().GetValue()
+		}
+	}
+	if skipped != 1 {
+		t.Errorf(`container_health_check_skipped{reason="annotation"} = %v, want 1`, skipped)
+	}
+}
+
+// 注解列出的 pod 标签附加到逐容器指标上，非法字符替换为下划线，没有该标签时取空值
+func TestAnnotationLabelsCopied(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	pod := newTestPod("a", ip, port)
+	pod.Labels = map[string]string{"team": "payment", "app.kubernetes.io/name": "checkout"}
+	pod.Annotations = map[string]string{labelsAnnotation: "team, app.kubernetes.io/name,tier"}
+	c := newTestMetrics(t, DefaultConfig(), pod)
+
+	up := samples(t, collectMetrics(c), "container_health_check_up")
+	if len(up) != 1 {
+		t.Fatalf("got %d container_health_check_up samples, want 1", len(up))
+	}
+	want := map[string]string{"team": "payment", "app_kubernetes_io_name": "checkout", "tier": ""}
+	for name, value := range want {
+		if got := labelValue(up[0], name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+// 超过 maxAnnotationLabels 的标签按名称排序后忽略
+func TestAnnotationLabelsCapped(t *testing.T) {
+	pod := newTestPod("a", "192.0.2.1", 8080)
+	pod.Annotations = map[string]string{labelsAnnotation: "g,f,e,d,c,b,a"}
+	keys, names := annotationLabels(pod, nil)
+	if want := []string{"a", "b", "c", "d", "e"}; strings.Join(names, ",") != strings.Join(want, ",") || len(keys) != len(names) {
+		t.Errorf("annotationLabels() = %v, want %v", names, want)
+	}
+}
+
+// 注解修改后不再使用的 Desc 在下一次抓取结束时清理
+func TestAnnotatedDescsReaped(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	pod := newTestPod("a", ip, port)
+	pod.Annotations = map[string]string{labelsAnnotation: "team"}
+	c := newTestMetrics(t, DefaultConfig(), pod)
+	collectMetrics(c)
+	if len(c.annotatedDescs.descs) == 0 {
+		t.Fatal("no annotated descs cached after the first scrape")
+	}
+
+	pod.Annotations = nil
+	if _, err := c.clientset.CoreV1().Pods(pod.Namespace).Update(context.Background(), pod, metaV1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	collectMetrics(c)
+	if n := len(c.annotatedDescs.descs); n != 0 {
+		t.Errorf("%d annotated descs left after the annotation was removed, want 0", n)
+	}
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ * 接口：Collect
+ * 功能：抓取最新的数据，传递给channel。
+ *      设置了 --cache.ttl 时，距离上一次成功抓取不到 TTL 的抓取直接返回缓存的指标，不再重新探测；
+ *      并发的抓取在锁上等待，第一个抓取完成后其余的直接使用它的结果，不会叠加探测压力。
+ *      List pod 失败的抓取不缓存，下一次抓取会立即重试
+ */
+func (c *Metrics) Collect(ch chan<- prometheus.Metric) {
+	if c.cfg.CacheTTL <= 0 {
+		c.collect(ch)
+		return
+	}
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if c.cached != nil && time.Since(c.cachedAt) < c.cfg.CacheTTL {
+		for _, m := range c.cached {
+			ch <- m
+		}
+		return
+	}
+
+	buf := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var collected []prometheus.Metric
+	go func() {
+		defer close(done)
+		for m := range buf {
+			collected = append(collected, m)
+			ch <- m
+		}
+	}()
+	start := time.Now()
+	ok := c.collect(buf)
+	close(buf)
+	<-done
+
+	c.cached, c.cachedAt = nil, time.Time{}
+	if ok {
+		// TTL 从抓取开始计算，与 Prometheus 的抓取间隔对齐
+		c.cached, c.cachedAt = collected, start
+	}
+}
@@ -2,19 +2,29 @@ package collector
 
 import (
 	"context"
-	"flag"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	coreV1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -24,10 +34,50 @@ import (
  * @return {*}
  */
 type Metrics struct {
-	metrics    map[string]*prometheus.Desc
-	mutex      sync.Mutex
-	clientset  *kubernetes.Clientset
-	httpClient *http.Client
+	cfg     Config
+	metrics map[string]*prometheus.Desc
+	// 按 health-check.exporter/labels 注解追加标签的 Desc
+	descSpecs      map[string]descSpec
+	annotatedDescs annotatedDescs
+	clientset      kubernetes.Interface
+	// --pod.watch 开启时从 informer 缓存读取 pod
+	podListers  []corelisters.PodLister
+	podStores   []cache.Store
+	httpClient  *http.Client
+	dialer      *net.Dialer
+	dnsFailures *prometheus.CounterVec
+	timeToReady *prometheus.HistogramVec
+	state       *stateStore
+	concurrency *concurrencyController
+	retryPolicy *retryPolicy
+	pathTmpl    *pathTemplate
+	probeQuery  url.Values
+
+	pathTemplateFallbacks prometheus.Counter
+	probePanics           prometheus.Counter
+	ambiguousPorts        prometheus.Counter
+	scrapeErrors          prometheus.Counter
+	annotationLabelErrors prometheus.Counter
+
+	// 最近一次抓取的探测结果，供状态页使用
+	resultsMu     sync.RWMutex
+	lastResults   []*probeResult
+	lastResultsAt time.Time
+
+	// 最近一次 List pod 的结果，供 /healthz 使用
+	listMu      sync.Mutex
+	lastListAt  time.Time
+	lastListErr error
+	// 最近一次请求 API server 版本接口的结果，在 serverVersionTTL 内复用；versionDone 非空表示请求正在进行
+	versionMu   sync.Mutex
+	versionAt   time.Time
+	versionErr  error
+	versionDone chan struct{}
+
+	// --cache.ttl 内重复的抓取直接返回上一次的指标
+	cacheMu  sync.Mutex
+	cached   []prometheus.Metric
+	cachedAt time.Time
 }
 
 /*
@@ -39,8 +89,24 @@ type Metrics struct {
   - @param: labels   标签信息
   - @return
 */
-func newGlobalMetric(metricName string, docString string, labels []string) *prometheus.Desc {
-	return prometheus.NewDesc(metricName, docString, labels, nil)
+func (cfg Config) newGlobalMetric(metricName string, docString string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(cfg.MetricNamespace, "", metricName), docString, labels, cfg.constLabels())
+}
+
+/**
+ * @function: constLabels
+ * @desc: 所有指标共用的固定标签，多副本（分片或高可用）部署时通过 replica 标签区分由哪个副本探测
+ */
+func (cfg Config) constLabels() prometheus.Labels {
+	replica := cfg.InstanceLabel
+	if replica == "" {
+		// 通过 downward API 注入的 pod 名称
+		replica = os.Getenv("POD_NAME")
+	}
+	if replica == "" {
+		return nil
+	}
+	return prometheus.Labels{"replica": replica}
 }
 
 func homeDir() string {
@@ -50,47 +116,234 @@ func homeDir() string {
 	return os.Getenv("USERPROFILE") // windows
 }
 
-// 初始化Metrics 结构体信息
-func NewMetrics() *Metrics {
+/**
+ * @function: newClientset
+ * @desc: 在集群内运行时使用 in-cluster 配置，否则使用 kubeconfig 中的当前 context
+ */
+func newClientset(kubeconfig string) (kubernetes.Interface, error) {
 	var config *rest.Config
 	var err error
 	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" && os.Getenv("KUBERNETES_SERVICE_PORT") != "" {
 		// creates the in-cluster config
 		config, err = rest.InClusterConfig()
 		if err != nil {
-			panic(err.Error())
+			return nil, fmt.Errorf("load in-cluster config: %w", err)
 		}
 	} else {
 		// creates the out-of-cluster config
-		var kubeconfig *string
-		if home := homeDir(); home != "" {
-			kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-		} else {
-			kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-		}
-		flag.Parse()
-
 		// use the current context in kubeconfig
-		config, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
 		if err != nil {
-			panic(err.Error())
+			return nil, fmt.Errorf("load kubeconfig %s: %w", kubeconfig, err)
 		}
-
 	}
 
 	// creates the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err.Error())
+		return nil, fmt.Errorf("create Kubernetes client: %w", err)
+	}
+	return clientset, nil
+}
+
+// 初始化Metrics 结构体信息，配置不合法或无法连接 Kubernetes 时返回错误
+func NewMetrics(cfg Config) (*Metrics, error) {
+	if cfg.Aggregate != aggregatePod && cfg.Aggregate != aggregateWorkload {
+		return nil, fmt.Errorf("unsupported aggregate level %q", cfg.Aggregate)
+	}
+	if cfg.ProbeIPFamily != ipFamilyAny && cfg.ProbeIPFamily != ipFamilyIPv4 && cfg.ProbeIPFamily != ipFamilyIPv6 {
+		return nil, fmt.Errorf("unsupported probe IP family %q", cfg.ProbeIPFamily)
+	}
+	if cfg.MetricTimestamp != timestampScrape && cfg.MetricTimestamp != timestampProbe {
+		return nil, fmt.Errorf("unsupported metric timestamp policy %q", cfg.MetricTimestamp)
+	}
+	if _, err := labels.Parse(cfg.PodSelector); err != nil {
+		return nil, fmt.Errorf("invalid --pod.selector %q: %w", cfg.PodSelector, err)
+	}
+	if cfg.ProbeTimeout <= 0 {
+		return nil, fmt.Errorf("--probe.timeout must be positive, got %s", cfg.ProbeTimeout)
+	}
+	if !sort.Float64sAreSorted(cfg.HistogramBuckets) {
+		return nil, fmt.Errorf("--histogram.buckets must be in increasing order, got %v", cfg.HistogramBuckets)
+	}
+	if cfg.TargetScrapeDuration > 0 && cfg.ProbeConcurrency <= 0 {
+		return nil, errors.New("--target-scrape-duration requires --probe.concurrency to be set")
+	}
+
+	var pathTmpl *pathTemplate
+	if cfg.ProbePathTemplate != "" {
+		var err error
+		pathTmpl, err = parsePathTemplate(cfg.ProbePathTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query, err := url.ParseQuery(cfg.ProbeQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --probe-query %q: %w", cfg.ProbeQuery, err)
+	}
+
+	policy, err := parseRetryPolicy(cfg.RetryableConditions)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer, err := newProbeDialer(cfg.ProbeSourceIP, cfg.ProbeConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset := cfg.Clientset
+	if clientset == nil {
+		if clientset, err = newClientset(cfg.Kubeconfig); err != nil {
+			return nil, err
+		}
+	}
+
+	// 逐个容器输出的指标记录帮助信息和基础标签，按注解追加标签时据此重新构造 Desc
+	descSpecs := map[string]descSpec{}
+	podDesc := func(name, help string, extraLabels ...string) *prometheus.Desc {
+		labels := append(cfg.podMetricLabels(), extraLabels...)
+		descSpecs[name] = descSpec{help: help, labels: labels}
+		return cfg.newGlobalMetric(name, help, labels)
+	}
+
+	var podListers []corelisters.PodLister
+	var podStores []cache.Store
+	if cfg.PodWatch {
+		if podListers, podStores, err = startPodInformers(clientset, cfg); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Metrics{
 		metrics: map[string]*prometheus.Desc{
-			"container_health_check_duration_millisecond": newGlobalMetric("container_health_check_duration_millisecond", "The time(millisecond) taken to invoke the health check interface", []string{"namespace", "container_name", "pod_name"}),
+			"container_health_check_duration_millisecond":              podDesc("container_health_check_duration_millisecond", "The time(millisecond) taken to invoke the health check interface"),
+			"container_health_check_clock_skew_seconds":                podDesc("container_health_check_clock_skew_seconds", "The difference in seconds between the Date header returned by the health check interface and the local time"),
+			"container_health_check_summary":                           podDesc("container_health_check_summary", "Whether the latest health check of the container succeeded (1) or not (0), labelled with the pod phase and container restart count", "phase", "restart_count"),
+			"container_restarts_total":                                 cfg.newGlobalMetric("container_restarts_total", "The number of times the container has been restarted, from the restart count in the pod status", []string{"namespace", "container_name", "pod_name"}),
+			"container_last_termination_reason":                        cfg.newGlobalMetric("container_last_termination_reason", "Information about the last termination of the container; probe_driven is true when the exit code (137 or 143, not OOMKilled) suggests the kubelet killed it after failed liveness probes", []string{"namespace", "container_name", "pod_name", "reason", "exit_code", "probe_driven"}),
+			"container_health_check_duration_seconds":                  cfg.newGlobalMetric("container_health_check_duration_seconds", "The time in seconds taken by the successful health checks of the container; every scrape adds one observation per container", []string{"namespace", "container_name", "pod_name"}),
+			"container_health_check_failures_total":                    cfg.newGlobalMetric("container_health_check_failures_total", "The number of failed health checks of the container", []string{"namespace", "container_name", "pod_name"}),
+			"container_health_check_transitions_total":                 cfg.newGlobalMetric("container_health_check_transitions_total", "The number of times the health check of the container flipped between success (direction=up) and failure (direction=down)", []string{"namespace", "container_name", "pod_name", "direction"}),
+			"container_health_check_exec_probe_restart":                podDesc("container_health_check_exec_probe_restart", "Whether the last restart of a container with an exec liveness probe looks probe-driven (1) or not (0), inferred from its last termination state"),
+			"container_health_check_up":                                podDesc("container_health_check_up", "Whether the health check succeeded (1) or not (0); HTTP checks succeed on 2xx and 3xx responses"),
+			"container_health_check_response_code":                     podDesc("container_health_check_response_code", "The HTTP status code returned by the health check interface, 0 when the request failed"),
+			"container_health_check_redirect_count":                    podDesc("container_health_check_redirect_count", "The number of redirects followed by the health check request"),
+			"container_health_check_node_avg_latency_seconds":          cfg.newGlobalMetric("container_health_check_node_avg_latency_seconds", "The average time in seconds taken by the successful health checks of the pods on a node", []string{"node"}),
+			"container_health_check_sample_size":                       cfg.newGlobalMetric("container_health_check_sample_size", "The number of pods selected for health checks by --probe-sample-fraction", nil),
+			"container_health_check_skipped":                           cfg.newGlobalMetric("container_health_check_skipped", "The number of pods skipped during the scrape, by reason", []string{"reason"}),
+			"container_health_check_informer_cache_objects":            cfg.newGlobalMetric("container_health_check_informer_cache_objects", "The number of pods held in the informer cache of --pod.watch", nil),
+			"container_health_check_namespaces_observed":               cfg.newGlobalMetric("container_health_check_namespaces_observed", "The number of distinct namespaces with at least one pod probed during the scrape", nil),
+			"container_health_check_pods_without_ip":                   cfg.newGlobalMetric("container_health_check_pods_without_ip", "The number of listed pods that have not been assigned a pod IP yet", nil),
+			"health_check_exporter_pods_total":                         cfg.newGlobalMetric("health_check_exporter_pods_total", "The number of pods returned by the pod list of the scrape, after --namespace and --pod.selector filtering", nil),
+			"health_check_exporter_pods_probed_total":                  cfg.newGlobalMetric("health_check_exporter_pods_probed_total", "The number of pods with at least one container health check performed during the scrape", nil),
+			"health_check_exporter_probe_concurrency":                  cfg.newGlobalMetric("health_check_exporter_probe_concurrency", "The number of health checks allowed to run concurrently during the scrape (0 means unlimited)", nil),
+			"container_health_check_workload_duration_millisecond_max": cfg.newGlobalMetric("container_health_check_workload_duration_millisecond_max", "The worst time(millisecond) taken to invoke the health check interface among the pods of a workload", workloadLabels),
+			"container_health_check_workload_failures":                 cfg.newGlobalMetric("container_health_check_workload_failures", "The number of pods of a workload whose health check failed", workloadLabels),
+			"container_health_check_workload_targets":                  cfg.newGlobalMetric("container_health_check_workload_targets", "The number of pods of a workload whose health check was invoked", workloadLabels),
 		},
+		descSpecs:  descSpecs,
 		clientset:  clientset,
-		httpClient: &http.Client{Timeout: 3 * time.Second},
+		podListers: podListers,
+		podStores:  podStores,
+		httpClient: &http.Client{Timeout: cfg.ProbeTimeout, Transport: newProbeTransport(dialer, cfg.ProbeInsecureSkipVerify), CheckRedirect: countRedirects},
+		dialer:     dialer,
+		dnsFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "container_health_check_dns_failures_total",
+			Help:        "The number of health checks that failed because the target name could not be resolved",
+			Namespace:   cfg.MetricNamespace,
+			ConstLabels: cfg.constLabels(),
+		}, []string{"namespace"}),
+		timeToReady: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "container_health_check_time_to_ready_seconds",
+			Help:        "The time in seconds from pod creation until its health check first succeeded, observed once per newly created pod",
+			Namespace:   cfg.MetricNamespace,
+			ConstLabels: cfg.constLabels(),
+			Buckets:     []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+		}, []string{"namespace"}),
+		state:       newStateStore(),
+		concurrency: newConcurrencyController(cfg.MinConcurrency, cfg.ProbeConcurrency, cfg.TargetScrapeDuration),
+		retryPolicy: policy,
+		pathTmpl:    pathTmpl,
+		probeQuery:  query,
+		cfg:         cfg,
+		pathTemplateFallbacks: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "container_health_check_path_template_fallbacks_total",
+			Help:        "The number of health checks that used the path of the probe because --probe-path-template could not be resolved",
+			Namespace:   cfg.MetricNamespace,
+			ConstLabels: cfg.constLabels(),
+		}),
+		ambiguousPorts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "container_health_check_ambiguous_port_total",
+			Help:        "The number of named probe ports declared with different port numbers by several containers of the pod",
+			Namespace:   cfg.MetricNamespace,
+			ConstLabels: cfg.constLabels(),
+		}),
+		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "health_check_exporter_scrape_errors_total",
+			Help:        "The number of scrapes that failed to list pods from the Kubernetes API",
+			Namespace:   cfg.MetricNamespace,
+			ConstLabels: cfg.constLabels(),
+		}),
+		annotationLabelErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "container_health_check_annotation_label_errors_total",
+			Help:        "The number of samples dropped because the labels requested by the health-check.exporter/labels annotation of the pod could not be added",
+			Namespace:   cfg.MetricNamespace,
+			ConstLabels: cfg.constLabels(),
+		}),
+		probePanics: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "container_health_check_probe_panics_total",
+			Help:        "The number of health check goroutines that panicked and were recovered",
+			Namespace:   cfg.MetricNamespace,
+			ConstLabels: cfg.constLabels(),
+		}),
+	}, nil
+}
+
+/**
+ * @function: newProbeDialer
+ * @desc: 构建 HTTP 和 TCP 探测共用的 Dialer，connectTimeout 只限制建立连接的时间，整个探测的超时由 --probe.timeout 控制；
+ *        指定 sourceIP 时所有探测连接都从该地址发起，启动时先尝试在该地址上监听一次，尽早发现地址不属于本机等无法绑定的问题
+ */
+func newProbeDialer(sourceIP string, connectTimeout time.Duration) (*net.Dialer, error) {
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	if sourceIP == "" {
+		return dialer, nil
+	}
+
+	ip := net.ParseIP(sourceIP)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid --probe-source-ip %q: not an IP address", sourceIP)
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot bind --probe-source-ip %s: %w", sourceIP, err)
+	}
+	l.Close()
+
+	dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	return dialer, nil
+}
+
+/**
+ * @function: newProbeTransport
+ * @desc: 构建健康检查共用的 Transport，只在 NewMetrics 中创建一次。
+ *        每个目标每次抓取只探测一次（重试也是串行的），每个目标保留一个空闲连接即可在下一次抓取中复用，
+ *        同时避免大集群中每个 pod 占用多个空闲连接
+ */
+func newProbeTransport(dialer *net.Dialer, insecureSkipVerify bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = hostAliasesDialer(dialer.DialContext)
+	transport.MaxIdleConnsPerHost = 1
+	if insecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
+	return transport
 }
 
 /**
@@ -102,30 +355,56 @@ func (c *Metrics) Describe(ch chan<- *prometheus.Desc) {
 	for _, m := range c.metrics {
 		ch <- m
 	}
+	c.dnsFailures.Describe(ch)
+	c.timeToReady.Describe(ch)
+	c.pathTemplateFallbacks.Describe(ch)
+	c.probePanics.Describe(ch)
+	c.ambiguousPorts.Describe(ch)
+	c.scrapeErrors.Describe(ch)
+	c.annotationLabelErrors.Describe(ch)
 }
 
+// container_health_check_skipped 的 reason 标签，每次抓取都输出全部原因，没有跳过时为 0
+var skipReasons = []string{"terminating", "annotation", "not_sampled", "not_running", "ip_family", "no_ip"}
+
 /**
- * 接口：Collect
- * 功能：抓取最新的数据，传递给channel
+ * @function: collect
+ * @desc: 抓取最新的数据，传递给channel，List pod 失败时返回 false
  */
-func (c *Metrics) Collect(ch chan<- prometheus.Metric) {
+func (c *Metrics) collect(ch chan<- prometheus.Metric) bool {
 
 	/*
-		使用了互斥锁来保护两个共享资源：
-			1、Metrics 结构体中的 clientset 字段：假设 clientset 是一个用于与 Kubernetes API 交互的客户端集合，
-				可能会被多个 goroutine 同时访问。通过在访问 clientset 之前加锁，确保了在同一时间只有一个 goroutine
-				能够访问 clientset，避免了对 clientset 的并发访问导致的竞态条件和数据竞争问题。
-			2、ch 通道：ch 是一个用于传递指标数据的通道，可能会被多个 goroutine 同时操作。通过在向 ch 发送数据之前加锁，
-				确保了在同一时间只有一个 goroutine 能够向 ch 发送数据，避免了多个 goroutine 同时向 ch 发送数据导致的数据竞争问题。
+		Collect 不再整体加锁，多个抓取可以同时进行：
+			1、clientset 本身是并发安全的，可以被多个 goroutine 同时使用。
+			2、ch 通道本身是并发安全的，而且每个健康检查 goroutine 只写自己的 probeResult，指标统一在 wg.Wait() 之后发送。
+			3、真正跨抓取共享的可变状态（探测目标状态表、并发控制、状态页结果）各自加锁保护。
 	*/
-	c.mutex.Lock() // 加锁
-	defer c.mutex.Unlock()
+	start := time.Now()
+	// 整个抓取共用一个带截止时间的 context，Prometheus 放弃本次抓取后不再让探测 goroutine 继续占用连接
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if c.cfg.ScrapeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.ScrapeTimeout)
+	}
+	defer cancel()
+
+	if c.podStores != nil {
+		ch <- prometheus.MustNewConstMetric(c.metrics["container_health_check_informer_cache_objects"], prometheus.GaugeValue, float64(c.cachedObjects()))
+	}
 
-	pods, err := c.clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+	pods, err := c.listPods(ctx)
+	c.recordList(err)
 	if err != nil {
-		panic(err.Error())
+		// API server 短暂不可用时只让本次抓取失败，不能让整个 exporter 退出
+		slog.Error("Failed to list pods", "err", err)
+		c.scrapeErrors.Inc()
+		c.scrapeErrors.Collect(ch)
+		if c.cfg.FailScrapeOnListError {
+			// 无效指标会让 promhttp 返回 500，Prometheus 自身的 up 指标即可反映本次抓取失败
+			ch <- prometheus.NewInvalidMetric(c.metrics["container_health_check_duration_millisecond"], err)
+		}
+		return false
 	}
-	items := pods.Items
+	items := interleaveNamespaces(pods)
 	/*
 		sync.WaitGroup 用于等待一组 goroutine 完成任务的同步机制。它的作用是确保在一组 goroutine 中的所有任务都完成后，
 			主 goroutine 才能继续执行。
@@ -133,66 +412,368 @@ func (c *Metrics) Collect(ch chan<- prometheus.Metric) {
 
 		在代码中的作用体现如下：
 		1、在 for 循环之外声明 WaitGroup 对象 wg，表示需要等待多个 goroutine 完成任务。
-		2、在 for 循环中，每启动一个新的健康检查 goroutine，都会调用 wg.Add(1) 方法，表示需要等待一个 goroutine 完成任务。
-		3、在每个健康检查 goroutine 中，完成任务后都会调用 wg.Done() 方法，表示一个 goroutine 已经完成任务。
-		4、在主 goroutine 中，调用 wg.Wait() 方法，等待所有的健康检查 goroutine 完成任务。只有当所有的 goroutine
+		2、分发任务前调用 wg.Add(len(tasks)) 方法，表示需要等待所有健康检查任务完成。
+		3、worker 每完成一个健康检查任务，都会调用 wg.Done() 方法，表示一个任务已经完成。
+		4、在主 goroutine 中，调用 wg.Wait() 方法，等待所有的健康检查任务完成。只有当所有的任务
 			都调用了 wg.Done() 方法后，wg.Wait() 方法才会返回，主 goroutine 才能继续执行。
 	*/
+	// 固定数量的 worker 从队列中领取健康检查任务，防止大集群中一次抓取同时发起成千上万个连接，耗尽文件描述符和源端口
+	concurrency := c.concurrency.size()
+
 	var wg sync.WaitGroup
+	alive := make(map[string]struct{}, len(items))
+	var tasks []probeTask
+	withoutIP := 0
+	sampleSize := 0
+	skipped := map[string]int{}
 	for _, item := range items {
-		wg.Add(1)
+		if item.DeletionTimestamp != nil && !c.cfg.ProbeTerminating {
+			// 默认不探测正在删除的 pod
+			skipped["terminating"]++
+			continue
+		}
+		if scrapeDisabled(&item) {
+			// 应用通过注解关闭了探测
+			skipped["annotation"]++
+			continue
+		}
+		if !sampled(item.UID, c.cfg.ProbeSampleFraction) {
+			skipped["not_sampled"]++
+			continue
+		}
+		sampleSize++
+		if item.Status.PodIP == "" {
+			// 调度高峰期大量 pod 尚未分配 IP，可用于解释探测覆盖率的下降
+			withoutIP++
+		}
+		if item.Status.Phase != coreV1.PodRunning && !c.cfg.IncludeNotReady {
+			// Pending、Succeeded、Failed 的 pod 探测必然失败，默认跳过
+			skipped["not_running"]++
+			continue
+		}
+		podIP, ok := selectPodIP(item.Status, c.cfg.ProbeIPFamily)
+		if !ok {
+			// 非完全双栈的集群中部分 pod 没有指定协议族的地址
+			skipped["ip_family"]++
+			continue
+		}
+		if podIP == "" {
+			// 没有 IP 时无法构造探测地址，即使指定了 --include-not-ready 也跳过
+			skipped["no_ip"]++
+			continue
+		}
 		tmp := item
-		/*
-			实现Collect方法，将pods健康信息写入ch(即 prometheus.Metric)
-		*/
-		go healthCheck(&tmp, c, ch, &wg)
+		// 多容器的 pod（例如应用容器加 Envoy sidecar）中每个配置了探针的容器单独探测，没有探针的容器跳过
+		for j := range tmp.Spec.Containers {
+			container := &tmp.Spec.Containers[j]
+			if !hasProbe(container) {
+				continue
+			}
+			alive[targetKey(tmp.UID, container.Name)] = struct{}{}
+			tasks = append(tasks, probeTask{pod: &tmp, container: container, podIP: podIP})
+		}
+	}
+
+	// 每个容器对应一个健康检查 goroutine，wg 按容器数计数
+	results := make([]*probeResult, len(tasks))
+	workers := concurrency
+	if workers <= 0 || workers > len(tasks) {
+		workers = len(tasks)
+	}
+	queue := make(chan int)
+	wg.Add(len(tasks))
+	for w := 0; w < workers; w++ {
+		go func() {
+			/*
+				实现Collect方法，将pods健康信息写入ch(即 prometheus.Metric)
+			*/
+			for i := range queue {
+				healthCheck(ctx, tasks[i], c, &results[i], &wg)
+			}
+		}()
+	}
+	for i := range tasks {
+		queue <- i
 	}
+	close(queue)
 
 	wg.Wait()
+	c.concurrency.adjust(time.Since(start))
+	ch <- prometheus.MustNewConstMetric(c.metrics["health_check_exporter_probe_concurrency"], prometheus.GaugeValue, float64(concurrency))
+	ch <- prometheus.MustNewConstMetric(c.metrics["container_health_check_pods_without_ip"], prometheus.GaugeValue, float64(withoutIP))
+	ch <- prometheus.MustNewConstMetric(c.metrics["container_health_check_sample_size"], prometheus.GaugeValue, float64(sampleSize))
+	// 列出的 pod 数与实际探测的 pod 数相差很大时，通常是过滤条件或探针配置出了问题
+	probedPods := map[types.UID]struct{}{}
+	for _, r := range results {
+		if r != nil {
+			probedPods[r.pod.UID] = struct{}{}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.metrics["health_check_exporter_pods_total"], prometheus.GaugeValue, float64(len(pods)))
+	ch <- prometheus.MustNewConstMetric(c.metrics["health_check_exporter_pods_probed_total"], prometheus.GaugeValue, float64(len(probedPods)))
+	for _, reason := range skipReasons {
+		ch <- prometheus.MustNewConstMetric(c.metrics["container_health_check_skipped"], prometheus.GaugeValue, float64(skipped[reason]), reason)
+	}
+	c.state.reap(alive)
+
+	if c.cfg.Aggregate == aggregateWorkload {
+		c.collectWorkloads(ch, results)
+	} else {
+		// 失败次数、状态切换次数和耗时直方图都带有 pod_name，聚合模式下不输出，否则序列数仍随 pod 数增长
+		c.state.collectCounters(ch, c.metrics["container_health_check_failures_total"], c.metrics["container_health_check_transitions_total"])
+		if len(c.cfg.HistogramBuckets) > 0 {
+			c.state.collectHistograms(ch, c.metrics["container_health_check_duration_seconds"], c.cfg.HistogramBuckets)
+		}
+		c.collectPods(ch, results)
+	}
+	c.annotatedDescs.reap()
+	c.collectNodes(ch, results)
+	c.collectRestarts(ch, items)
+
+	namespaces := map[string]struct{}{}
+	for _, r := range results {
+		if r != nil {
+			namespaces[r.pod.Namespace] = struct{}{}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.metrics["container_health_check_namespaces_observed"], prometheus.GaugeValue, float64(len(namespaces)))
+
+	c.storeResults(results)
+	if c.cfg.LogResults {
+		logResults(c.cfg.ResultLogger, results, c.cfg.LogResultsSample)
+	}
+	if c.cfg.LogScrapeSummary {
+		logSummary(len(pods), results, skipped, time.Since(start))
+	}
+	c.dnsFailures.Collect(ch)
+	c.timeToReady.Collect(ch)
+	c.pathTemplateFallbacks.Collect(ch)
+	c.probePanics.Collect(ch)
+	c.ambiguousPorts.Collect(ch)
+	c.scrapeErrors.Collect(ch)
+	c.annotationLabelErrors.Collect(ch)
+	return true
 }
 
-func healthCheck(pod *coreV1.Pod, c *Metrics, ch chan<- prometheus.Metric, waitGroup *sync.WaitGroup) {
-	defer waitGroup.Done()
+// 探测耗时转换为毫秒，与指标名称的单位一致；失败时的 -1 保持不变
+func milliseconds(d time.Duration) float64 {
+	if d < 0 {
+		return -1
+	}
+	return float64(d) / float64(time.Millisecond)
+}
+
+/**
+ * @function: probeResult
+ * @desc: 单个容器一次健康检查的结果，由 Collect 统一转换成指标
+ */
+type probeResult struct {
+	pod           *coreV1.Pod
+	containerName string
+	url           string
+	duration      time.Duration // 探测失败时为 -1
+	statusCode    int           // 请求失败时为 0
+	err           error
+	restartCount  int32
+	image         string
+	port          int
+	handler       string // 探针类型：httpget、tcpsocket、exec、grpc
+	probeRestart  bool   // exec 探针：最近一次重启是否由探针导致
+	redirects     int
+	method        string
+	timestamp     time.Time
+	clockSkew     float64
+	hasClockSkew  bool // 响应中没有可解析的 Date 头时为 false
+}
+
+// 样本时间戳策略
+const (
+	timestampScrape = "scrape"
+	timestampProbe  = "probe"
+)
+
+/**
+ * @function: stampMetric
+ * @desc: 按 --metric-timestamp（或 --metric.honor-timestamps）决定是否给耗时样本附加时间戳，
+ *        只作用于 container_health_check_duration_millisecond，其余指标始终由 Prometheus 使用抓取时间：
+ *        scrape（默认）：不附加时间戳，由 Prometheus 使用抓取时间，staleness 处理和 rate() 的行为与普通 exporter 一致；
+ *        probe：附加探测完成的时间，能反映精确的探测时刻，但样本时间早于抓取时间，
+ *               目标消失后序列不会被及时标记为 stale，相邻抓取的时间戳间隔不均匀也会让 rate() 的结果产生抖动，
+ *               时间戳回退时样本还会被当作乱序丢弃
+ *        添加时间戳后的样本形如 container_health_check_duration_millisecond{container_name="",namespace="kube-system",
+ *        pod_name="cilium-mk95x"} -1 1715059230118（时间戳）
+ */
+func (c *Metrics) stampMetric(metric prometheus.Metric, probeTime time.Time) prometheus.Metric {
+	if c.cfg.MetricTimestamp == timestampProbe {
+		return prometheus.NewMetricWithTimestamp(probeTime, metric)
+	}
+	return metric
+}
+
+// 是否有可用的耗时数据：失败的探测和 exec 探针没有真实的耗时
+func (r *probeResult) hasLatency() bool {
+	return r.err == nil && r.handler != handlerExec
+}
+
+/**
+ * @function: collectRestarts
+ * @desc: 输出每个容器的重启次数和上一次终止的原因，直接取自 pod 状态中的 containerStatuses，
+ *        与是否配置了探针、是否完成探测、聚合级别都无关，便于把探测延迟与探针导致的重启对应起来
+ */
+func (c *Metrics) collectRestarts(ch chan<- prometheus.Metric, pods []coreV1.Pod) {
+	for i := range pods {
+		pod := &pods[i]
+		if scrapeDisabled(pod) {
+			continue
+		}
+		for j := range pod.Status.ContainerStatuses {
+			cs := &pod.Status.ContainerStatuses[j]
+			ch <- prometheus.MustNewConstMetric(c.metrics["container_restarts_total"], prometheus.GaugeValue, float64(cs.RestartCount), pod.Namespace, cs.Name, pod.Name)
+			if terminated := cs.LastTerminationState.Terminated; terminated != nil {
+				ch <- prometheus.MustNewConstMetric(c.metrics["container_last_termination_reason"], prometheus.GaugeValue, 1,
+					pod.Namespace, cs.Name, pod.Name, terminated.Reason, strconv.Itoa(int(terminated.ExitCode)), strconv.FormatBool(probeDrivenRestart(cs)))
+			}
+		}
+	}
+}
 
-	meta := pod.ObjectMeta
-	spec := pod.Spec
-	status := pod.Status
-	podName := meta.Name
-	labels := meta.Labels
-	containerName := labels["app"]
+/**
+ * @function: collectPods
+ * @desc: 按 pod 输出健康检查指标
+ */
+func (c *Metrics) collectPods(ch chan<- prometheus.Metric, results []*probeResult) {
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		// exec 探针的容器只输出重启推断，容器在运行不代表探针成功，不能输出 up=1
+		if r.handler == handlerExec {
+			probeRestart := 0.0
+			if r.probeRestart {
+				probeRestart = 1
+			}
+			c.sendPodMetric(ch, "container_health_check_exec_probe_restart", probeRestart, r)
+			continue
+		}
+		up := 0.0
+		if r.err == nil {
+			up = 1
+		}
+		c.sendPodMetric(ch, "container_health_check_up", up, r)
 
-	livenessProbe := spec.Containers[0].LivenessProbe
+		// 耗时只在探测成功时输出，-1 会被当成真实的延迟影响 avg() 等聚合结果
+		if c.cfg.DurationGauge && (r.hasLatency() || (r.err != nil && c.cfg.LatencyOnFailure)) {
+			if metric, ok := c.podMetric("container_health_check_duration_millisecond", milliseconds(r.duration), r); ok {
+				ch <- c.stampMetric(metric, r.timestamp)
+			}
+		}
 
-	if livenessProbe != nil && livenessProbe.HTTPGet != nil {
-		podIP := status.PodIP
-		httpGet := livenessProbe.HTTPGet
+		if r.hasClockSkew {
+			c.sendPodMetric(ch, "container_health_check_clock_skew_seconds", r.clockSkew, r)
+		}
 
-		start := time.Now()
+		if r.handler == handlerHTTPGet {
+			// 区分“接口慢”和“接口报错”：快速返回 500 的探测耗时与正常探测没有区别
+			c.sendPodMetric(ch, "container_health_check_response_code", float64(r.statusCode), r)
+		}
 
-		var scheme string
-		if coreV1.URISchemeHTTP == httpGet.Scheme {
-			scheme = "http://"
-		} else {
-			scheme = "https://"
+		if c.cfg.ProbeCountRedirects && r.handler == handlerHTTPGet {
+			c.sendPodMetric(ch, "container_health_check_redirect_count", float64(r.redirects), r)
 		}
 
-		resp, err := c.httpClient.Get(scheme + podIP + ":" + strconv.Itoa(int(httpGet.Port.IntVal)) + httpGet.Path)
+		if c.cfg.EmitSummary {
+			// 把探测结果、pod 阶段和重启次数汇总到一条序列，省去仪表盘中多个指标的 join
+			c.sendPodMetric(ch, "container_health_check_summary", up, r, string(r.pod.Status.Phase), strconv.Itoa(int(r.restartCount)))
+		}
+	}
+}
 
-		var duration time.Duration
-		if err != nil {
-			duration = -1
-		} else {
-			duration = time.Since(start)
+/**
+ * @function: logSummary
+ * @desc: 每次抓取结束后输出一行汇总日志，不依赖 Prometheus 也能快速了解本次探测的整体情况
+ */
+func logSummary(listed int, results []*probeResult, skipped map[string]int, elapsed time.Duration) {
+	probed, succeeded := 0, 0
+	for _, r := range results {
+		if r == nil || r.handler == handlerExec {
+			continue
 		}
+		probed++
+		if r.err == nil {
+			succeeded++
+		}
+	}
 
-		if resp != nil {
-			defer resp.Body.Close()
+	reasons := make([]string, 0, len(skipped))
+	for reason := range skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	var b strings.Builder
+	for i, reason := range reasons {
+		if i > 0 {
+			b.WriteString(",")
 		}
-		metric := prometheus.MustNewConstMetric(c.metrics["container_health_check_duration_millisecond"], prometheus.GaugeValue, float64(duration), meta.Namespace, containerName, podName)
-		// 添加时间戳 container_health_check_duration_millisecond{container_name="",namespace="kube-system",
-		// pod_name="cilium-mk95x"} -1 1715059230118（时间戳）
-		ch <- prometheus.NewMetricWithTimestamp(time.Now(), metric)
+		fmt.Fprintf(&b, "%s:%d", reason, skipped[reason])
+	}
 
+	slog.Info("Scrape finished", "listed", listed, "probed", probed, "succeeded", succeeded,
+		"failed", probed-succeeded, "skipped", b.String(), "duration", elapsed)
+}
+
+/**
+ * @function: collectNodes
+ * @desc: 按节点输出本次抓取中探测成功的平均耗时，单个节点明显偏慢通常意味着 CNI 或硬件问题，序列数以节点数为上限
+ */
+func (c *Metrics) collectNodes(ch chan<- prometheus.Metric, results []*probeResult) {
+	type nodeLatency struct {
+		total time.Duration
+		count int
+	}
+	nodes := map[string]*nodeLatency{}
+	for _, r := range results {
+		if r == nil || !r.hasLatency() || r.pod.Spec.NodeName == "" {
+			continue
+		}
+		n, ok := nodes[r.pod.Spec.NodeName]
+		if !ok {
+			n = &nodeLatency{}
+			nodes[r.pod.Spec.NodeName] = n
+		}
+		n.total += r.duration
+		n.count++
+	}
+	for node, n := range nodes {
+		ch <- prometheus.MustNewConstMetric(c.metrics["container_health_check_node_avg_latency_seconds"], prometheus.GaugeValue, (n.total / time.Duration(n.count)).Seconds(), node)
 	}
+}
 
+// 探测失败的错误分类
+const (
+	errorClassDNS               = "dns"
+	errorClassTimeout           = "timeout"
+	errorClassConnectionRefused = "connection_refused"
+	errorClassConnectionReset   = "connection_reset"
+	errorClassOther             = "other"
+)
+
+/**
+ * @function: classifyError
+ * @desc: 将探测请求返回的错误归类，DNS 错误需要优先判断（DNS 超时同样满足 net.Error.Timeout）
+ */
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return errorClassDNS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errorClassTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errorClassConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return errorClassConnectionReset
+	default:
+		return errorClassOther
+	}
 }
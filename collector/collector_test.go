@@ -0,0 +1,271 @@
+package collector
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// 测试用的 pod：一个容器，在 ip:port 上配置了 httpGet 存活探针
+func newTestPod(name, ip string, port int) *coreV1.Pod {
+	return &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), Labels: map[string]string{"app": name}},
+		Spec: coreV1.PodSpec{
+			Containers: []coreV1.Container{{
+				Name: "app",
+				LivenessProbe: &coreV1.Probe{
+					ProbeHandler: coreV1.ProbeHandler{
+						HTTPGet: &coreV1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(port), Scheme: coreV1.URISchemeHTTP},
+					},
+				},
+			}},
+		},
+		Status: coreV1.PodStatus{Phase: coreV1.PodRunning, PodIP: ip},
+	}
+}
+
+// 使用 fake clientset 按 cfg 构造 Metrics
+func newTestMetrics(t *testing.T, cfg Config, pods ...runtime.Object) *Metrics {
+	t.Helper()
+	cfg.Clientset = fake.NewSimpleClientset(pods...)
+	c, err := NewMetrics(cfg)
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	return c
+}
+
+// 启动测试用的 HTTP 服务，返回它监听的 IP 和端口
+func newTestServer(t *testing.T, handler http.Handler) (string, int) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+// 执行一次 Collect，返回输出的全部指标
+func collectMetrics(c prometheus.Collector) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	var metrics []prometheus.Metric
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+	}()
+	c.Collect(ch)
+	close(ch)
+	<-done
+	return metrics
+}
+
+// 按指标名称筛选，返回各样本的标签和值
+func samples(t *testing.T, metrics []prometheus.Metric, name string) []*dto.Metric {
+	t.Helper()
+	var out []*dto.Metric
+	for _, m := range metrics {
+		if !descHasName(m.Desc(), name) {
+			continue
+		}
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		out = append(out, &pb)
+	}
+	return out
+}
+
+// Desc 没有导出名称，只能从 String() 中匹配 fqName: "xxx"
+func descHasName(desc *prometheus.Desc, name string) bool {
+	return strings.Contains(desc.String(), `fqName: "`+name+`"`)
+}
+
+// 样本中指定标签的值
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// 聚合模式下不能再输出带 pod_name 的逐个 pod 的序列
+func TestWorkloadAggregationOmitsPodSeries(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	cfg := DefaultConfig()
+	cfg.Aggregate = aggregateWorkload
+	cfg.HistogramBuckets = []float64{0.1, 1}
+	c := newTestMetrics(t, cfg, newTestPod("a", ip, port), newTestPod("b", ip, port))
+
+	metrics := collectMetrics(c)
+	for _, name := range []string{"container_health_check_duration_millisecond", "container_health_check_failures_total", "container_health_check_transitions_total", "container_health_check_duration_seconds"} {
+		if got := samples(t, metrics, name); len(got) != 0 {
+			t.Errorf("%s: got %d per-pod samples with --aggregate=workload", name, len(got))
+		}
+	}
+}
+
+// 发往 panicHost 的请求直接 panic，模拟探测过程中出现的意外错误
+type panicTransport struct {
+	next      http.RoundTripper
+	panicHost string
+}
+
+func (t panicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.URL.Host, t.panicHost+":") {
+		panic("unexpected probe failure")
+	}
+	return t.next.RoundTrip(req)
+}
+
+// 单个探测 panic 时计数，其他目标的结果照常输出
+func TestHealthCheckPanic(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	c := newTestMetrics(t, DefaultConfig(), newTestPod("healthy", ip, port), newTestPod("broken", "192.0.2.1", port))
+	c.httpClient.Transport = panicTransport{next: c.httpClient.Transport, panicHost: "192.0.2.1"}
+
+	metrics := collectMetrics(c)
+
+	panics := samples(t, metrics, "container_health_check_probe_panics_total")
+	if len(panics) != 1 || panics[0].GetCounter().GetValue() != 1 {
+		t.Fatalf("container_health_check_probe_panics_total = %v, want 1", panics)
+	}
+	durations := samples(t, metrics, "container_health_check_duration_millisecond")
+	if len(durations) != 1 || labelValue(durations[0], "pod_name") != "healthy" {
+		t.Fatalf("container_health_check_duration_millisecond = %v, want only the healthy pod", durations)
+	}
+}
+
+// 两个抓取同时进行时不能死锁，-race 下也不能出现数据竞争
+func TestConcurrentCollect(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	c := newTestMetrics(t, DefaultConfig(), newTestPod("a", ip, port), newTestPod("b", ip, port), newTestPod("c", ip, port))
+
+	var wg sync.WaitGroup
+	results := make([][]prometheus.Metric, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = collectMetrics(c)
+		}(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("concurrent Collect calls did not finish")
+	}
+
+	for i, metrics := range results {
+		if got := samples(t, metrics, "container_health_check_duration_millisecond"); len(got) != 3 {
+			t.Fatalf("scrape %d: got %d container_health_check_duration_millisecond samples, want 3", i, len(got))
+		}
+	}
+}
+
+// 重启次数取自 containerStatuses，没有探针的 sidecar 在聚合模式下也要输出
+func TestRestartsFromContainerStatuses(t *testing.T) {
+	pod := newTestPod("a", "192.0.2.1", 8080)
+	pod.Spec.Containers = append(pod.Spec.Containers, coreV1.Container{Name: "sidecar"})
+	pod.Status.ContainerStatuses = []coreV1.ContainerStatus{
+		{Name: "app", RestartCount: 1},
+		{Name: "sidecar", RestartCount: 3, LastTerminationState: coreV1.ContainerState{
+			Terminated: &coreV1.ContainerStateTerminated{Reason: "Error", ExitCode: 137},
+		}},
+	}
+	cfg := DefaultConfig()
+	cfg.Aggregate = aggregateWorkload
+	cfg.ProbeTimeout = 200 * time.Millisecond
+	c := newTestMetrics(t, cfg, pod)
+
+	metrics := collectMetrics(c)
+	restarts := map[string]float64{}
+	for _, m := range samples(t, metrics, "container_restarts_total") {
+		restarts[labelValue(m, "container_name")] = m.GetGauge().GetValue()
+	}
+	if restarts["app"] != 1 || restarts["sidecar"] != 3 || len(restarts) != 2 {
+		t.Errorf("container_restarts_total = %v, want app=1 sidecar=3", restarts)
+	}
+	reasons := samples(t, metrics, "container_last_termination_reason")
+	if len(reasons) != 1 || labelValue(reasons[0], "container_name") != "sidecar" || labelValue(reasons[0], "probe_driven") != "true" {
+		t.Errorf("container_last_termination_reason = %v, want one probe-driven sample for sidecar", reasons)
+	}
+}
+
+// --metric-timestamp=probe 只给耗时样本附加探测时间，不支持的策略直接报错
+func TestMetricTimestampPolicy(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	cfg := DefaultConfig()
+	cfg.MetricTimestamp = timestampProbe
+	c := newTestMetrics(t, cfg, newTestPod("a", ip, port))
+
+	metrics := collectMetrics(c)
+	durations := samples(t, metrics, "container_health_check_duration_millisecond")
+	if len(durations) != 1 || durations[0].TimestampMs == nil {
+		t.Errorf("container_health_check_duration_millisecond = %v, want one sample with a timestamp", durations)
+	}
+	for _, m := range samples(t, metrics, "container_health_check_up") {
+		if m.TimestampMs != nil {
+			t.Errorf("container_health_check_up carries timestamp %d, want none", m.GetTimestampMs())
+		}
+	}
+
+	cfg.MetricTimestamp = "none"
+	cfg.Clientset = fake.NewSimpleClientset()
+	if _, err := NewMetrics(cfg); err == nil {
+		t.Error("NewMetrics accepted --metric-timestamp=none")
+	}
+}
+
+// exec 探针的容器只输出重启推断，不输出 up，也不计入失败次数
+func TestExecProbeOnlyReportsRestart(t *testing.T) {
+	pod := newTestPod("a", "192.0.2.1", 8080)
+	pod.Spec.Containers[0].LivenessProbe.ProbeHandler = coreV1.ProbeHandler{Exec: &coreV1.ExecAction{Command: []string{"true"}}}
+	pod.Status.ContainerStatuses = []coreV1.ContainerStatus{{Name: "app", RestartCount: 1, LastTerminationState: coreV1.ContainerState{
+		Terminated: &coreV1.ContainerStateTerminated{Reason: "Error", ExitCode: 137},
+	}}}
+	cfg := DefaultConfig()
+	cfg.NewTargetGraceFailures = 0
+	c := newTestMetrics(t, cfg, pod)
+
+	collectMetrics(c)
+	metrics := collectMetrics(c)
+	restarts := samples(t, metrics, "container_health_check_exec_probe_restart")
+	if len(restarts) != 1 || restarts[0].GetGauge().GetValue() != 1 {
+		t.Errorf("container_health_check_exec_probe_restart = %v, want 1", restarts)
+	}
+	for _, name := range []string{"container_health_check_up", "container_health_check_failures_total", "container_health_check_transitions_total"} {
+		if got := samples(t, metrics, name); len(got) != 0 {
+			t.Errorf("%s: got %d samples for an exec probe, want none", name, len(got))
+		}
+	}
+}
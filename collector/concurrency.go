@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+ * @function: concurrencyController
+ * @desc: 根据上一次抓取的耗时自动调整并发探测数：
+ *        耗时超过目标时按 1.5 倍增加并发，耗时低于目标的一半时按 3/4 减少并发，
+ *        其余情况保持不变，调整结果始终限制在 [min, max] 区间内。
+ *        未设置目标耗时时并发数固定为 max，max 为 0 表示不限制并发。
+ */
+type concurrencyController struct {
+	mu      sync.Mutex
+	current int
+	min     int
+	max     int
+	target  time.Duration
+}
+
+func newConcurrencyController(min, max int, target time.Duration) *concurrencyController {
+	if min < 1 {
+		min = 1
+	}
+	if max > 0 && min > max {
+		min = max
+	}
+	return &concurrencyController{current: max, min: min, max: max, target: target}
+}
+
+// 当前并发探测数，0 表示不限制
+func (c *concurrencyController) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+/**
+ * @function: adjust
+ * @desc: 根据本次抓取耗时调整下一次抓取的并发探测数
+ */
+func (c *concurrencyController) adjust(elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.target <= 0 || c.max <= 0 {
+		return
+	}
+	switch {
+	case elapsed > c.target:
+		c.current += c.current/2 + 1
+	case elapsed < c.target/2:
+		c.current = c.current * 3 / 4
+	}
+	if c.current > c.max {
+		c.current = c.max
+	}
+	if c.current < c.min {
+		c.current = c.min
+	}
+}
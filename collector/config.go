@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+/**
+ * @function: Config
+ * @desc: collector 的全部配置，由 main 从命令行参数填充，各字段的含义见对应命令行参数的说明。
+ *        collector 本身不读取全局的 flag，作为库使用时可以从 DefaultConfig 开始直接构造
+ */
+type Config struct {
+	// Kubernetes 连接与 pod 列表，Clientset 为 nil 时根据 in-cluster 配置或 Kubeconfig 创建，测试时可以传入 fake clientset
+	Clientset             kubernetes.Interface
+	Kubeconfig            string
+	Namespaces            []string
+	PodSelector           string
+	PodWatch              bool
+	PodSyncTimeout        time.Duration
+	FailScrapeOnListError bool
+
+	// 探测目标的选择
+	IncludeNotReady        bool
+	ProbeTerminating       bool
+	ProbeSampleFraction    float64
+	ProbeIPFamily          string
+	NewTargetGraceFailures int
+
+	// 探测行为
+	ProbeTimeout            time.Duration
+	ProbeConnectTimeout     time.Duration
+	ScrapeTimeout           time.Duration
+	ProbeConcurrency        int
+	MinConcurrency          int
+	TargetScrapeDuration    time.Duration
+	ProbeRetries            int
+	ProbeRetryBackoff       time.Duration
+	RetryableConditions     string
+	ProbeSourceIP           string
+	ProbeInsecureSkipVerify bool
+	ProbePreferHead         bool
+	ProbePathTemplate       string
+	ProbeQuery              string
+	ProbeClockSkew          bool
+	ProbeCountRedirects     bool
+
+	// 指标输出
+	MetricNamespace  string
+	MetricTimestamp  string
+	Aggregate        string
+	InstanceLabel    string
+	ImageTagLabel    bool
+	PortLabel        bool
+	AppLabel         bool
+	MaxLabelLength   int
+	LatencyOnFailure bool
+	DurationGauge    bool
+	HistogramBuckets []float64
+	EmitSummary      bool
+	CacheTTL         time.Duration
+
+	// 日志，ResultLogger 为 --log-results 使用的 logger，为 nil 时使用 slog.Default()
+	LogResults       bool
+	ResultLogger     *slog.Logger
+	LogResultsSample float64
+	LogScrapeSummary bool
+}
+
+/**
+ * @function: DefaultConfig
+ * @desc: 返回与命令行参数默认值一致的配置
+ */
+func DefaultConfig() Config {
+	var kubeconfig string
+	if home := homeDir(); home != "" {
+		kubeconfig = filepath.Join(home, ".kube", "config")
+	}
+	return Config{
+		Kubeconfig:          kubeconfig,
+		PodSyncTimeout:      time.Minute,
+		ProbeSampleFraction: 1,
+		ProbeIPFamily:       ipFamilyAny,
+		ProbeTimeout:        3 * time.Second,
+		ScrapeTimeout:       10 * time.Second,
+		ProbeConcurrency:    50,
+		MinConcurrency:      1,
+		ProbeRetries:        1,
+		ProbeRetryBackoff:   100 * time.Millisecond,
+		RetryableConditions: errorClassTimeout + "," + errorClassConnectionReset,
+		MetricTimestamp:     timestampScrape,
+		Aggregate:           aggregatePod,
+		MaxLabelLength:      128,
+		DurationGauge:       true,
+		LogResultsSample:    1,
+	}
+}
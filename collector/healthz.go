@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// /healthz 请求 API server 版本接口的结果缓存时间和等待上限，避免每次存活探针都请求一次 API server
+var (
+	serverVersionTTL     = 10 * time.Second
+	serverVersionTimeout = 3 * time.Second
+)
+
+var errServerVersionTimeout = errors.New("timed out waiting for the server version")
+
+// 记录最近一次 List pod 的结果
+func (c *Metrics) recordList(err error) {
+	c.listMu.Lock()
+	defer c.listMu.Unlock()
+	c.lastListAt, c.lastListErr = time.Now(), err
+}
+
+/**
+ * @function: HealthzHandler
+ * @desc: exporter 自身的存活检查：最近一次 List pod 成功时返回 200，失败时返回 503，
+ *        用于区分“exporter 正常但 API server 不可达”和“exporter 已经挂掉”。
+ *        启动后还没有被抓取过，或者通过 informer 缓存读取 pod（读取缓存不会失败）时，通过 API server 的版本接口判断是否可达
+ */
+func (c *Metrics) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	c.listMu.Lock()
+	listAt, err := c.lastListAt, c.lastListErr
+	c.listMu.Unlock()
+
+	if listAt.IsZero() || c.podListers != nil {
+		err = c.checkServerVersion()
+	}
+	if err != nil {
+		http.Error(w, "kubernetes API unreachable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+/**
+ * @function: checkServerVersion
+ * @desc: 请求 API server 的版本接口判断是否可达，结果缓存 serverVersionTTL。
+ *        同一时刻最多只有一个请求在进行，超过 serverVersionTimeout 仍未返回时本次检查直接失败，
+ *        请求返回后更新缓存，API server 无响应时存活探针不会被阻塞，也不会堆积请求
+ */
+func (c *Metrics) checkServerVersion() error {
+	c.versionMu.Lock()
+	if !c.versionAt.IsZero() && time.Since(c.versionAt) < serverVersionTTL {
+		err := c.versionErr
+		c.versionMu.Unlock()
+		return err
+	}
+	done := c.versionDone
+	if done == nil {
+		done = make(chan struct{})
+		c.versionDone = done
+		go func() {
+			_, err := c.clientset.Discovery().ServerVersion()
+			c.versionMu.Lock()
+			c.versionAt, c.versionErr, c.versionDone = time.Now(), err, nil
+			c.versionMu.Unlock()
+			close(done)
+		}()
+	}
+	c.versionMu.Unlock()
+
+	select {
+	case <-done:
+		c.versionMu.Lock()
+		defer c.versionMu.Unlock()
+		return c.versionErr
+	case <-time.After(serverVersionTimeout):
+		return errServerVersionTimeout
+	}
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// 请求 /healthz，返回状态码
+func healthz(c *Metrics) int {
+	rec := httptest.NewRecorder()
+	c.HealthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	return rec.Code
+}
+
+// 版本接口的结果在缓存时间内复用，失败同样缓存
+func TestHealthzCachesServerVersion(t *testing.T) {
+	var calls atomic.Int32
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls.Add(1)
+		return true, nil, errors.New("connection refused")
+	})
+	cfg := DefaultConfig()
+	cfg.Clientset = clientset
+	c, err := NewMetrics(cfg)
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := healthz(c); code != http.StatusServiceUnavailable {
+			t.Fatalf("request %d: /healthz = %d, want 503", i, code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server version requested %d times, want 1", n)
+	}
+}
+
+// API server 无响应时 /healthz 按时返回 503，并且不会重复发起请求
+func TestHealthzServerVersionTimeout(t *testing.T) {
+	timeout := serverVersionTimeout
+	serverVersionTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { serverVersionTimeout = timeout })
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls.Add(1)
+		<-release
+		return false, nil, nil
+	})
+	cfg := DefaultConfig()
+	cfg.Clientset = clientset
+	c, err := NewMetrics(cfg)
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		if code := healthz(c); code != http.StatusServiceUnavailable {
+			t.Fatalf("request %d: /healthz = %d, want 503", i, code)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("request %d: /healthz returned after %s", i, elapsed)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server version requested %d times, want 1", n)
+	}
+}
+
+// 抓取过且 List pod 成功时不再请求版本接口
+func TestHealthzAfterList(t *testing.T) {
+	var calls atomic.Int32
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls.Add(1)
+		return true, nil, errors.New("connection refused")
+	})
+	cfg := DefaultConfig()
+	cfg.Clientset = clientset
+	c, err := NewMetrics(cfg)
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+
+	collectMetrics(c)
+	if code := healthz(c); code != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200", code)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("server version requested %d times, want 0", n)
+	}
+}
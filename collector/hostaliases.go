@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"context"
+	"net"
+
+	coreV1 "k8s.io/api/core/v1"
+)
+
+type hostAliasesKey struct{}
+
+// 把 pod 的 spec.hostAliases 放进探测请求的 context，供拨号时解析
+func withHostAliases(ctx context.Context, aliases []coreV1.HostAlias) context.Context {
+	if len(aliases) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, hostAliasesKey{}, aliases)
+}
+
+/**
+ * @function: lookupHostAlias
+ * @desc: 与容器内 /etc/hosts 的行为一致，按 hostAliases 查找主机名对应的 IP
+ */
+func lookupHostAlias(ctx context.Context, host string) (string, bool) {
+	aliases, _ := ctx.Value(hostAliasesKey{}).([]coreV1.HostAlias)
+	for _, alias := range aliases {
+		for _, name := range alias.Hostnames {
+			if name == host {
+				return alias.IP, true
+			}
+		}
+	}
+	return "", false
+}
+
+/**
+ * @function: hostAliasesDialer
+ * @desc: 按域名探测时先查 pod 的 hostAliases，使测得的结果与应用自身看到的解析一致，没有匹配时走正常的 DNS 解析
+ */
+func hostAliasesDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) == nil {
+			if ip, ok := lookupHostAlias(ctx, host); ok {
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
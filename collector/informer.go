@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+/**
+ * @function: startPodInformers
+ * @desc: 启动 pod 的 shared informer，通过 watch 在本地维护 pod 缓存，抓取时直接读取缓存，不再每次 List 整个集群。
+ *        指定了 --namespace 时每个命名空间一个 informer，--pod.selector 由 API server 过滤；
+ *        返回前等待所有缓存同步完成，保证第一次抓取就能看到完整的 pod 列表；
+ *        超过 --pod.sync-timeout 仍未同步（通常是缺少 watch 权限或 API server 不可达）时停止 informer 并返回错误
+ */
+func startPodInformers(clientset kubernetes.Interface, cfg Config) ([]corelisters.PodLister, []cache.Store, error) {
+	watched := []string{metav1.NamespaceAll}
+	if len(cfg.Namespaces) > 0 {
+		watched = cfg.Namespaces
+	}
+
+	// 同步成功后 informer 随进程一直运行，不需要停止
+	stop := make(chan struct{})
+	var listers []corelisters.PodLister
+	var stores []cache.Store
+	var synced []cache.InformerSynced
+	for _, ns := range watched {
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+			informers.WithNamespace(ns),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = cfg.PodSelector
+			}))
+		podInformer := factory.Core().V1().Pods()
+		// managedFields 占用大量内存且探测用不到，缓存前丢弃
+		podInformer.Informer().SetTransform(func(obj interface{}) (interface{}, error) {
+			if pod, ok := obj.(*coreV1.Pod); ok {
+				pod.ManagedFields = nil
+			}
+			return obj, nil
+		})
+		listers = append(listers, podInformer.Lister())
+		stores = append(stores, podInformer.Informer().GetStore())
+		synced = append(synced, podInformer.Informer().HasSynced)
+		factory.Start(stop)
+	}
+
+	start := time.Now()
+	slog.Info("Waiting for the pod cache to sync", "timeout", cfg.PodSyncTimeout)
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if cfg.PodSyncTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.PodSyncTimeout)
+	}
+	defer cancel()
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		close(stop)
+		return nil, nil, fmt.Errorf("pod cache did not sync within %s, check that the service account can list and watch pods or disable --pod.watch", cfg.PodSyncTimeout)
+	}
+	slog.Info("Pod cache synced", "duration", time.Since(start).Round(time.Millisecond))
+	return listers, stores, nil
+}
+
+// informer 缓存中的 pod 数量，用于评估大集群中 exporter 的内存占用
+func (c *Metrics) cachedObjects() int {
+	n := 0
+	for _, store := range c.podStores {
+		n += len(store.ListKeys())
+	}
+	return n
+}
+
+/**
+ * @function: cachedPods
+ * @desc: 从 informer 缓存中读取当前的 pod。缓存中的对象是共享的，只能读取不能修改
+ */
+func (c *Metrics) cachedPods() ([]coreV1.Pod, error) {
+	var items []coreV1.Pod
+	for _, lister := range c.podListers {
+		pods, err := lister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			items = append(items, *pod)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// 没有 watch 权限时缓存无法同步，NewMetrics 需要在超时后返回错误而不是一直阻塞
+func TestPodCacheSyncTimeout(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		return true, nil, errors.New(`pods is forbidden: cannot watch resource "pods"`)
+	})
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New(`pods is forbidden: cannot list resource "pods"`)
+	})
+	cfg := DefaultConfig()
+	cfg.Clientset = clientset
+	cfg.PodWatch = true
+	cfg.PodSyncTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	if _, err := NewMetrics(cfg); err == nil {
+		t.Fatal("NewMetrics succeeded without a synced pod cache")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("NewMetrics returned after %s, want about --pod.sync-timeout", elapsed)
+	}
+}
+
+func TestPodCacheObjects(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PodWatch = true
+	cfg.PodSyncTimeout = 5 * time.Second
+	cfg.ProbeTimeout = 200 * time.Millisecond
+	c := newTestMetrics(t, cfg, newTestPod("a", "192.0.2.1", 8080), newTestPod("b", "192.0.2.2", 8080))
+
+	objects := samples(t, collectMetrics(c), "container_health_check_informer_cache_objects")
+	if len(objects) != 1 || objects[0].GetGauge().GetValue() != 2 {
+		t.Fatalf("container_health_check_informer_cache_objects = %v, want 2", objects)
+	}
+}
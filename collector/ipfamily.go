@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"net"
+
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// 探测使用的 IP 协议族
+const (
+	ipFamilyAny  = "any"
+	ipFamilyIPv4 = "ipv4"
+	ipFamilyIPv6 = "ipv6"
+)
+
+/**
+ * @function: selectPodIP
+ * @desc: 按 --probe-ip-family 从 pod 的 IP 中选出探测地址，双栈集群中 status.podIPs 包含两个协议族的地址；
+ *        any 时沿用 status.podIP，pod 没有指定协议族的地址时返回 false
+ */
+func selectPodIP(status coreV1.PodStatus, family string) (string, bool) {
+	if family == ipFamilyAny {
+		return status.PodIP, true
+	}
+	for _, podIP := range status.PodIPs {
+		ip := net.ParseIP(podIP.IP)
+		if ip == nil {
+			continue
+		}
+		if isIPv4 := ip.To4() != nil; isIPv4 == (family == ipFamilyIPv4) {
+			return podIP.IP, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/**
+ * @function: podMetricLabels
+ * @desc: 逐个容器输出的指标的标签，可选标签根据命令行参数追加
+ */
+func (cfg Config) podMetricLabels() []string {
+	labels := []string{"namespace", "container_name", "pod_name", "probe_handler"}
+	if cfg.ProbeTerminating {
+		labels = append(labels, "terminating")
+	}
+	if cfg.ImageTagLabel {
+		labels = append(labels, "image_tag")
+	}
+	if cfg.PortLabel {
+		labels = append(labels, "port")
+	}
+	if cfg.ProbePreferHead {
+		labels = append(labels, "method")
+	}
+	if cfg.AppLabel {
+		labels = append(labels, "app")
+	}
+	return labels
+}
+
+/**
+ * @function: podLabelValues
+ * @desc: 与 podMetricLabels 一一对应的标签值
+ */
+func (c *Metrics) podLabelValues(r *probeResult) []string {
+	values := []string{r.pod.Namespace, r.containerName, r.pod.Name, r.handler}
+	if c.cfg.ProbeTerminating {
+		terminating := "0"
+		if r.pod.DeletionTimestamp != nil {
+			terminating = "1"
+		}
+		values = append(values, terminating)
+	}
+	if c.cfg.ImageTagLabel {
+		values = append(values, c.truncateLabel(imageTag(r.image)))
+	}
+	if c.cfg.PortLabel {
+		values = append(values, strconv.Itoa(r.port))
+	}
+	if c.cfg.ProbePreferHead {
+		values = append(values, r.method)
+	}
+	if c.cfg.AppLabel {
+		// 旧版本把 pod 的 app 标签当作 container_name 输出，这里单独作为 app 标签兼容
+		values = append(values, c.truncateLabel(r.pod.Labels["app"]))
+	}
+	return values
+}
+
+/**
+ * @function: imageTag
+ * @desc: 从容器镜像中提取版本标签用于灰度对比：固定 digest 的镜像取 digest 的前 12 位，
+ *        没有标签的镜像视为 latest，标签中的非法字符替换为下划线
+ */
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		digest := image[i+1:]
+		if j := strings.Index(digest, ":"); j >= 0 {
+			digest = digest[j+1:]
+		}
+		if len(digest) > 12 {
+			digest = digest[:12]
+		}
+		return sanitizeTag(digest)
+	}
+
+	tag := "latest"
+	// 仓库地址可能带端口，只在最后一个 / 之后查找 :
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		tag = name[i+1:]
+	}
+	return sanitizeTag(tag)
+}
+
+/**
+ * @function: truncateLabel
+ * @desc: 截断来自 pod 标签、镜像等外部数据的标签值，防止异常数据撑大指标，--max-label-length 为 0 时不截断
+ */
+func (c *Metrics) truncateLabel(value string) string {
+	if c.cfg.MaxLabelLength <= 0 || len(value) <= c.cfg.MaxLabelLength {
+		return value
+	}
+	// 按字节截断时避免切断多字节字符
+	cut := c.cfg.MaxLabelLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
+
+func sanitizeTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, tag)
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+)
+
+/**
+ * @function: logResults
+ * @desc: 把探测结果逐条写到 logger（默认为标准输出上与 --log.format、--log.level 一致的 logger），
+ *        供基于日志的采集链路使用；大集群中可以通过 --log-results-sample 只记录一部分结果，避免日志刷屏
+ */
+func logResults(logger *slog.Logger, results []*probeResult, sample float64) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		if sample < 1 && rand.Float64() >= sample {
+			continue
+		}
+		latency := milliseconds(r.duration)
+		if r.err != nil {
+			latency = -1
+		}
+		attrs := []slog.Attr{
+			slog.String("namespace", r.pod.Namespace),
+			slog.String("pod", r.pod.Name),
+			slog.String("container", r.containerName),
+			slog.String("url", r.url),
+			slog.Float64("latency_ms", latency),
+		}
+		if r.statusCode != 0 {
+			attrs = append(attrs, slog.Int("status_code", r.statusCode))
+		}
+		if r.err != nil {
+			attrs = append(attrs, slog.String("error", r.err.Error()))
+		}
+		logger.LogAttrs(context.Background(), slog.LevelInfo, "Health check result", attrs...)
+	}
+}
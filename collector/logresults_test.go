@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLogResults(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	pod := newTestPod("a", "192.0.2.1", 8080)
+	results := []*probeResult{
+		{pod: pod, containerName: "app", url: "http://192.0.2.1:8080/healthz", duration: 15 * time.Millisecond, statusCode: 200},
+		nil,
+		{pod: pod, containerName: "app", url: "http://192.0.2.1:8080/healthz", duration: time.Second, err: errors.New("timeout")},
+	}
+	logResults(logger, results, 1)
+
+	var lines []map[string]any
+	for dec := json.NewDecoder(&buf); dec.More(); {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if lines[0]["level"] != "INFO" || lines[0]["latency_ms"] != 15.0 || lines[0]["status_code"] != 200.0 {
+		t.Errorf("success line = %v", lines[0])
+	}
+	if lines[1]["latency_ms"] != -1.0 || lines[1]["error"] != "timeout" {
+		t.Errorf("failure line = %v", lines[1])
+	}
+}
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/**
+ * @function: listPods
+ * @desc: 列出待探测的 pod：开启 --pod.watch 时读取 informer 缓存；没有指定 --namespace 时列出整个集群，否则对每个命名空间分别 List，
+ *        任意一个命名空间失败都让本次抓取失败，避免只上报部分命名空间的结果。--pod.selector 由 API server 过滤
+ */
+func (c *Metrics) listPods(ctx context.Context) ([]coreV1.Pod, error) {
+	if c.podListers != nil {
+		return c.cachedPods()
+	}
+	opts := metav1.ListOptions{LabelSelector: c.cfg.PodSelector}
+	if len(c.cfg.Namespaces) == 0 {
+		pods, err := c.clientset.CoreV1().Pods("").List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return pods.Items, nil
+	}
+
+	var items []coreV1.Pod
+	for _, ns := range c.cfg.Namespaces {
+		pods, err := c.clientset.CoreV1().Pods(ns).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", ns, err)
+		}
+		items = append(items, pods.Items...)
+	}
+	return items, nil
+}
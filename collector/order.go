@@ -0,0 +1,29 @@
+package collector
+
+import coreV1 "k8s.io/api/core/v1"
+
+/**
+ * @function: interleaveNamespaces
+ * @desc: 按命名空间轮询重排待探测的 pod，避免一次抓取的前几秒全部花在某个大命名空间上，
+ *        抓取被提前截断时已有的结果也能覆盖整个集群。命名空间按首次出现的顺序轮询，同一命名空间内保持原有顺序
+ */
+func interleaveNamespaces(items []coreV1.Pod) []coreV1.Pod {
+	var namespaces []string
+	byNamespace := map[string][]coreV1.Pod{}
+	for _, item := range items {
+		if _, ok := byNamespace[item.Namespace]; !ok {
+			namespaces = append(namespaces, item.Namespace)
+		}
+		byNamespace[item.Namespace] = append(byNamespace[item.Namespace], item)
+	}
+
+	ordered := make([]coreV1.Pod, 0, len(items))
+	for i := 0; len(ordered) < len(items); i++ {
+		for _, ns := range namespaces {
+			if pods := byNamespace[ns]; i < len(pods) {
+				ordered = append(ordered, pods[i])
+			}
+		}
+	}
+	return ordered
+}
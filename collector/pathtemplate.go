@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+)
+
+/**
+ * @function: pathTemplate
+ * @desc: 探测路径模板，支持以下占位符：
+ *        {namespace}、{pod}、{container}：pod 的命名空间、名称以及被探测容器的名称
+ *        {label:<key>}、{annotation:<key>}：pod 的标签、注解的值
+ *        例如 /health/{label:version}
+ */
+type pathTemplate struct {
+	segments []templateSegment
+}
+
+// 模板片段，kind 为空时 value 是原样输出的文本
+type templateSegment struct {
+	kind  string
+	value string
+}
+
+/**
+ * @function: parsePathTemplate
+ * @desc: 启动时解析并校验路径模板
+ */
+func parsePathTemplate(s string) (*pathTemplate, error) {
+	t := &pathTemplate{}
+	for s != "" {
+		open := strings.Index(s, "{")
+		if open < 0 {
+			if strings.Contains(s, "}") {
+				return nil, fmt.Errorf("invalid probe path template: unexpected }")
+			}
+			t.segments = append(t.segments, templateSegment{value: s})
+			break
+		}
+		if strings.Contains(s[:open], "}") {
+			return nil, fmt.Errorf("invalid probe path template: unexpected }")
+		}
+		if open > 0 {
+			t.segments = append(t.segments, templateSegment{value: s[:open]})
+		}
+		end := strings.Index(s[open:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid probe path template: unclosed {")
+		}
+		seg, err := parsePlaceholder(s[open+1 : open+end])
+		if err != nil {
+			return nil, err
+		}
+		t.segments = append(t.segments, seg)
+		s = s[open+end+1:]
+	}
+	return t, nil
+}
+
+func parsePlaceholder(p string) (templateSegment, error) {
+	switch p {
+	case "namespace", "pod", "container":
+		return templateSegment{kind: p}, nil
+	}
+	kind, key, ok := strings.Cut(p, ":")
+	if !ok || key == "" || (kind != "label" && kind != "annotation") {
+		return templateSegment{}, fmt.Errorf("invalid probe path template: unknown placeholder {%s}", p)
+	}
+	return templateSegment{kind: kind, value: key}, nil
+}
+
+/**
+ * @function: resolve
+ * @desc: 用 pod 的元数据填充模板，占位符对应的标签或注解不存在时返回 false，由调用方回退到探针中配置的路径
+ */
+func (t *pathTemplate) resolve(pod *coreV1.Pod, container string) (string, bool) {
+	var b strings.Builder
+	for _, seg := range t.segments {
+		var value string
+		var ok bool
+		switch seg.kind {
+		case "":
+			b.WriteString(seg.value)
+			continue
+		case "namespace":
+			value, ok = pod.Namespace, true
+		case "pod":
+			value, ok = pod.Name, true
+		case "container":
+			value, ok = container, true
+		case "label":
+			value, ok = pod.Labels[seg.value]
+		case "annotation":
+			value, ok = pod.Annotations[seg.value]
+		}
+		if !ok || value == "" {
+			return "", false
+		}
+		b.WriteString(url.PathEscape(value))
+	}
+	return b.String(), true
+}
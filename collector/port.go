@@ -0,0 +1,43 @@
+package collector
+
+import (
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+/**
+ * @function: resolvePort
+ * @desc: 解析探针中的端口，命名端口按名称查找容器声明的 containerPort：
+ *        优先使用被探测容器自身声明的端口，其次使用 pod 中第一个声明了该名称的容器的端口。
+ *        多个容器以同一名称声明了不同的端口号时结果存在歧义，返回的 ambiguous 为 true
+ */
+func resolvePort(pod *coreV1.Pod, container *coreV1.Container, port intstr.IntOrString) (value int, ambiguous bool, ok bool) {
+	if port.Type != intstr.String {
+		return port.IntValue(), false, true
+	}
+
+	numbers := map[int32]struct{}{}
+	var first int32
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name != port.StrVal {
+				continue
+			}
+			if len(numbers) == 0 {
+				first = p.ContainerPort
+			}
+			numbers[p.ContainerPort] = struct{}{}
+		}
+	}
+	if len(numbers) == 0 {
+		return 0, false, false
+	}
+	ambiguous = len(numbers) > 1
+
+	for _, p := range container.Ports {
+		if p.Name == port.StrVal {
+			return int(p.ContainerPort), ambiguous, true
+		}
+	}
+	return int(first), ambiguous, true
+}
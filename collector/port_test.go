@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestResolvePort(t *testing.T) {
+	pod := &coreV1.Pod{Spec: coreV1.PodSpec{Containers: []coreV1.Container{
+		{Name: "app", Ports: []coreV1.ContainerPort{{Name: "http", ContainerPort: 8080}, {Name: "admin", ContainerPort: 9000}}},
+		{Name: "sidecar", Ports: []coreV1.ContainerPort{{Name: "http", ContainerPort: 15020}, {Name: "admin", ContainerPort: 9000}}},
+		{Name: "no-ports"},
+	}}}
+
+	tests := []struct {
+		name          string
+		container     int
+		port          intstr.IntOrString
+		want          int
+		wantAmbiguous bool
+		wantOK        bool
+	}{
+		{"number", 0, intstr.FromInt(8081), 8081, false, true},
+		{"prefer own container", 1, intstr.FromString("http"), 15020, true, true},
+		{"own container listed first", 0, intstr.FromString("http"), 8080, true, true},
+		{"first match", 2, intstr.FromString("http"), 8080, true, true},
+		{"same number is not ambiguous", 2, intstr.FromString("admin"), 9000, false, true},
+		{"undeclared name", 0, intstr.FromString("grpc"), 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ambiguous, ok := resolvePort(pod, &pod.Spec.Containers[tt.container], tt.port)
+			if got != tt.want || ambiguous != tt.wantAmbiguous || ok != tt.wantOK {
+				t.Errorf("resolvePort() = (%d, %v, %v), want (%d, %v, %v)", got, ambiguous, ok, tt.want, tt.wantAmbiguous, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// 探针类型，对应 probe_handler 标签
+const (
+	handlerHTTPGet   = "httpget"
+	handlerTCPSocket = "tcpsocket"
+	handlerExec      = "exec"
+	handlerGRPC      = "grpc"
+)
+
+/**
+ * @function: probeTask
+ * @desc: 一次健康检查的目标：pod 中某个配置了探针的容器
+ */
+type probeTask struct {
+	pod       *coreV1.Pod
+	container *coreV1.Container
+	podIP     string
+}
+
+/**
+ * @function: probeHandler
+ * @desc: 返回容器存活探针的类型，没有配置可以探测的存活探针时返回空字符串
+ */
+func probeHandler(container *coreV1.Container) string {
+	probe := container.LivenessProbe
+	switch {
+	case probe == nil:
+		return ""
+	case probe.HTTPGet != nil:
+		return handlerHTTPGet
+	case probe.TCPSocket != nil:
+		return handlerTCPSocket
+	case probe.Exec != nil:
+		return handlerExec
+	case probe.GRPC != nil:
+		return handlerGRPC
+	}
+	return ""
+}
+
+// 容器是否配置了可以探测的存活探针
+func hasProbe(container *coreV1.Container) bool {
+	return probeHandler(container) != ""
+}
+
+/**
+ * @function: healthCheck
+ * @desc: 探测一个目标，ctx 为整个抓取的 context，抓取超时后尚未完成或尚未开始的探测立即失败
+ */
+func healthCheck(ctx context.Context, task probeTask, c *Metrics, result **probeResult, waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+	pod, container := task.pod, task.container
+	// 单个异常的 pod 对象（例如意外的 nil）不能导致整个进程崩溃，恢复后记录堆栈并计数
+	defer func() {
+		if r := recover(); r != nil {
+			c.probePanics.Inc()
+			slog.Error("Health check panicked", "namespace", pod.Namespace, "pod", pod.Name, "container", container.Name,
+				"panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	meta := pod.ObjectMeta
+	r := &probeResult{
+		pod:           pod,
+		containerName: container.Name,
+		image:         container.Image,
+		handler:       probeHandler(container),
+	}
+
+	var ok bool
+	switch r.handler {
+	case handlerHTTPGet:
+		ok = c.checkHTTPGet(ctx, task, r)
+	case handlerTCPSocket:
+		ok = c.checkTCPSocket(ctx, task, r)
+	case handlerExec:
+		ok = checkExec(task, r)
+	case handlerGRPC:
+		ok = c.checkGRPC(ctx, task, r)
+	}
+	if !ok {
+		return
+	}
+	r.timestamp = time.Now()
+	// exec 探针在容器内执行，exporter 拿不到探测结果，只输出重启推断，不参与宽限期和失败、状态切换计数
+	if r.handler == handlerExec {
+		*result = r
+		return
+	}
+
+	if r.err != nil {
+		r.duration = -1
+		switch classifyError(r.err) {
+		case errorClassDNS:
+			// 域名解析失败单独计数，便于区分集群 DNS 问题与应用自身问题
+			c.dnsFailures.WithLabelValues(meta.Namespace).Inc()
+		}
+	}
+
+	// 新目标在宽限次数内连续失败时暂不上报，避免 pod 刚分配 IP、路由尚未就绪导致的误报
+	key := targetKey(meta.UID, container.Name)
+	st := c.state.observe(key, []string{meta.Namespace, container.Name, meta.Name}, r.err == nil, c.cfg.NewTargetGraceFailures)
+	if st.inGrace(c.cfg.NewTargetGraceFailures) {
+		return
+	}
+	if r.err == nil {
+		// 新建 pod 首次探测成功时记录一次从创建到可用的耗时，之后不再跟踪
+		if elapsed, ok := c.state.markReady(key, meta.CreationTimestamp.Time); ok {
+			c.timeToReady.WithLabelValues(meta.Namespace).Observe(elapsed.Seconds())
+		}
+		if len(c.cfg.HistogramBuckets) > 0 && r.hasLatency() {
+			c.state.observeDuration(key, r.duration.Seconds(), c.cfg.HistogramBuckets)
+		}
+	}
+
+	if cs := containerStatus(pod, container.Name); cs != nil {
+		r.restartCount = cs.RestartCount
+	}
+	*result = r
+}
+
+/**
+ * @function: timeoutFor
+ * @desc: 单次探测的超时：探针配置了 timeoutSeconds 时取它与 --probe.timeout 中较小的一个，
+ *        否则使用 --probe.timeout。apiserver 会把未设置的 timeoutSeconds 默认为 1 秒，
+ *        因此大多数探针实际使用 kubelet 相同的超时，--probe.timeout 作为上限
+ */
+func (c *Metrics) timeoutFor(probe *coreV1.Probe) time.Duration {
+	if probe != nil && probe.TimeoutSeconds > 0 {
+		if t := time.Duration(probe.TimeoutSeconds) * time.Second; t < c.cfg.ProbeTimeout {
+			return t
+		}
+	}
+	return c.cfg.ProbeTimeout
+}
+
+/**
+ * @function: resolveProbePort
+ * @desc: 解析探针端口，存在歧义时计数；找不到命名端口时记录日志并返回 false，避免探测 :0
+ */
+func (c *Metrics) resolveProbePort(task probeTask, port intstr.IntOrString) (int, bool) {
+	value, ambiguous, ok := resolvePort(task.pod, task.container, port)
+	if ambiguous {
+		c.ambiguousPorts.Inc()
+	}
+	if !ok {
+		slog.Debug("Skipping health check: named port is not declared by the pod", "namespace", task.pod.Namespace,
+			"pod", task.pod.Name, "container", task.container.Name, "port", port.StrVal)
+	}
+	return value, ok
+}
+
+/**
+ * @function: checkHTTPGet
+ * @desc: 按 httpGet 探针发起 HTTP 请求
+ */
+func (c *Metrics) checkHTTPGet(ctx context.Context, task probeTask, r *probeResult) bool {
+	pod, container := task.pod, task.container
+	httpGet := container.LivenessProbe.HTTPGet
+
+	var scheme string
+	if coreV1.URISchemeHTTP == httpGet.Scheme {
+		scheme = "http://"
+	} else {
+		scheme = "https://"
+	}
+
+	path := httpGet.Path
+	if c.pathTmpl != nil {
+		if resolved, ok := c.pathTmpl.resolve(pod, container.Name); ok {
+			path = resolved
+		} else {
+			c.pathTemplateFallbacks.Inc()
+		}
+	}
+	path = appendQuery(path, c.probeQuery)
+
+	port, ok := c.resolveProbePort(task, httpGet.Port)
+	if !ok {
+		return false
+	}
+	r.port = port
+	r.url = probeURL(scheme, probeHost(task.podIP, httpGet), port, path)
+	header := probeHeader(httpGet.HTTPHeaders)
+
+	ctx = withRedirectCount(withHostAliases(ctx, pod.Spec.HostAliases), &r.redirects)
+	// HEAD 请求没有响应体，开销更小；不支持 HEAD 的接口返回 405 时改用 GET，默认与 kubelet 一致使用 GET
+	r.method = http.MethodGet
+	if c.cfg.ProbePreferHead {
+		r.method = http.MethodHead
+	}
+	timeout := c.timeoutFor(container.LivenessProbe)
+	resp, elapsed, err := c.probeHTTP(ctx, r.method, r.url, header, timeout)
+	if err == nil && r.method == http.MethodHead && resp.StatusCode == http.StatusMethodNotAllowed {
+		drainBody(resp)
+		r.method = http.MethodGet
+		resp, elapsed, err = c.probeHTTP(ctx, r.method, r.url, header, timeout)
+	}
+	r.duration, r.err = elapsed, err
+	if resp == nil {
+		return true
+	}
+	defer drainBody(resp)
+
+	r.statusCode = resp.StatusCode
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		// 与 kubelet 一致，2xx 和 3xx 之外的状态码视为失败
+		r.err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if c.cfg.ProbeClockSkew {
+		// Date 头只精确到秒，缺失或无法解析时直接忽略
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			r.clockSkew = time.Until(date).Seconds()
+			r.hasClockSkew = true
+		}
+	}
+	return true
+}
+
+// 与 kubelet 一致，探针指定了 host 时连接该地址，否则连接 pod IP
+func probeHost(podIP string, httpGet *coreV1.HTTPGetAction) string {
+	if httpGet.Host != "" {
+		return httpGet.Host
+	}
+	return podIP
+}
+
+// 拼接探测地址，IPv6 地址需要加方括号，例如 http://[fd00::1]:8080/healthz
+func probeURL(scheme, host string, port int, path string) string {
+	return scheme + net.JoinHostPort(host, strconv.Itoa(port)) + path
+}
+
+// 探针中配置的请求头，同名的请求头按配置顺序全部发送
+func probeHeader(headers []coreV1.HTTPHeader) http.Header {
+	header := http.Header{}
+	for _, h := range headers {
+		header.Add(h.Name, h.Value)
+	}
+	return header
+}
+
+/**
+ * @function: checkTCPSocket
+ * @desc: 按 tcpSocket 探针建立 TCP 连接，记录建立连接的耗时
+ */
+func (c *Metrics) checkTCPSocket(ctx context.Context, task probeTask, r *probeResult) bool {
+	port, ok := c.resolveProbePort(task, task.container.LivenessProbe.TCPSocket.Port)
+	if !ok {
+		return false
+	}
+	r.port = port
+	addr := net.JoinHostPort(task.podIP, strconv.Itoa(port))
+	r.url = "tcp://" + addr
+
+	timeout := c.timeoutFor(task.container.LivenessProbe)
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		conn, err := c.dialer.DialContext(attemptCtx, "tcp", addr)
+		r.duration, r.err = time.Since(start), err
+		cancel()
+		if conn != nil {
+			conn.Close()
+		}
+		// 与 httpGet 探针使用相同的重试条件和退避
+		wait := c.retryBackoff(attempt)
+		if err == nil || attempt >= c.cfg.ProbeRetries || !c.retryPolicy.retryable(nil, err) || !canRetry(ctx, wait) {
+			return true
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return true
+		}
+	}
+}
+
+/**
+ * @function: checkGRPC
+ * @desc: 按 grpc 探针调用标准的 grpc.health.v1.Health/Check 方法，返回 SERVING 视为成功。
+ *        与 kubelet 一致使用明文连接，耗时包括建立连接和调用 Check
+ */
+func (c *Metrics) checkGRPC(ctx context.Context, task probeTask, r *probeResult) bool {
+	probe := task.container.LivenessProbe.GRPC
+	r.port = int(probe.Port)
+	addr := net.JoinHostPort(task.podIP, strconv.Itoa(r.port))
+	r.url = "grpc://" + addr
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeoutFor(task.container.LivenessProbe))
+	defer cancel()
+	start := time.Now()
+	// passthrough 直接连接 pod IP，不经过 DNS 解析
+	conn, err := grpc.NewClient("passthrough:///"+addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return c.dialer.DialContext(ctx, "tcp", addr)
+		}))
+	if err != nil {
+		r.duration, r.err = time.Since(start), err
+		return true
+	}
+	defer conn.Close()
+
+	var service string
+	if probe.Service != nil {
+		service = *probe.Service
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	r.duration, r.err = time.Since(start), err
+	if err == nil && resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		r.err = fmt.Errorf("grpc health check status %s", resp.GetStatus())
+	}
+	return true
+}
+
+// exec 探针无法在 exporter 中执行，容器不在运行时视为失败
+var errContainerNotRunning = errors.New("container is not running")
+
+/**
+ * @function: checkExec
+ * @desc: exporter 不能通过 exec API 在容器中执行命令，退而根据容器状态判断：
+ *        容器处于运行状态视为成功，没有耗时数据；同时根据上一次终止的状态推断最近一次重启是否由探针导致
+ */
+func checkExec(task probeTask, r *probeResult) bool {
+	cs := containerStatus(task.pod, task.container.Name)
+	if cs == nil || cs.State.Running == nil {
+		r.err = errContainerNotRunning
+	}
+	if cs != nil {
+		r.probeRestart = probeDrivenRestart(cs)
+	}
+	return true
+}
+
+/**
+ * @function: probeDrivenRestart
+ * @desc: 存活探针失败时 kubelet 会先发送 SIGTERM，超过宽限期后发送 SIGKILL，
+ *        因此上一次终止的退出码为 143 或 137 且不是 OOMKilled 时，认为最近一次重启由探针导致。
+ *        容器状态中没有更准确的信息，这只是一个近似判断
+ */
+func probeDrivenRestart(cs *coreV1.ContainerStatus) bool {
+	terminated := cs.LastTerminationState.Terminated
+	if terminated == nil || terminated.Reason == "OOMKilled" {
+		return false
+	}
+	return terminated.ExitCode == 137 || terminated.ExitCode == 143
+}
+
+// 查找容器对应的状态
+func containerStatus(pod *coreV1.Pod, name string) *coreV1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
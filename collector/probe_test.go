@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+)
+
+func TestProbeURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		podIP   string
+		httpGet *coreV1.HTTPGetAction
+		want    string
+	}{
+		{"ipv4", "10.0.0.1", &coreV1.HTTPGetAction{}, "http://10.0.0.1:8080/healthz"},
+		{"ipv6", "fd00::1", &coreV1.HTTPGetAction{}, "http://[fd00::1]:8080/healthz"},
+		{"host override", "fd00::1", &coreV1.HTTPGetAction{Host: "health.internal"}, "http://health.internal:8080/healthz"},
+		{"ipv6 host override", "10.0.0.1", &coreV1.HTTPGetAction{Host: "fd00::2"}, "http://[fd00::2]:8080/healthz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := probeURL("http://", probeHost(tt.podIP, tt.httpGet), 8080, "/healthz"); got != tt.want {
+				t.Errorf("probeURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"net/url"
+	"strings"
+)
+
+/**
+ * @function: appendQuery
+ * @desc: 把 --probe-query 中的查询参数追加到探测路径上，路径本身已有查询串时合并两者，
+ *        通过 url.Values 编码保证转义正确
+ */
+func appendQuery(path string, extra url.Values) string {
+	if len(extra) == 0 {
+		return path
+	}
+	path, rawQuery, _ := strings.Cut(path, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// 探针中配置的查询串不合法时原样保留，只追加新的参数
+		return path + "?" + rawQuery + "&" + extra.Encode()
+	}
+	for key, values := range extra {
+		query[key] = append(query[key], values...)
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// 与 net/http 默认行为一致，最多跟随 10 次重定向
+const maxRedirects = 10
+
+type redirectCountKey struct{}
+
+// 在探测请求的 context 中放入重定向计数器
+func withRedirectCount(ctx context.Context, count *int) context.Context {
+	return context.WithValue(ctx, redirectCountKey{}, count)
+}
+
+/**
+ * @function: countRedirects
+ * @desc: 作为 http.Client 的 CheckRedirect，记录探测经过的重定向次数，超过上限时中止，便于发现重定向循环
+ */
+func countRedirects(req *http.Request, via []*http.Request) error {
+	if count, ok := req.Context().Value(redirectCountKey{}).(*int); ok {
+		*count = len(via)
+	}
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * @function: retryPolicy
+ * @desc: 判断一次失败的探测是否值得重试。条件分两类：
+ *        1、错误分类（见 classifyError）：dns、timeout、connection_refused、connection_reset、other
+ *        2、响应状态码：具体的状态码如 503，或者按百位匹配如 5xx
+ *        404 之类的永久性错误重试也不会恢复，默认只重试超时和连接被重置，避免浪费超时预算
+ */
+type retryPolicy struct {
+	classes     map[string]bool
+	codes       map[int]bool
+	codeClasses map[int]bool // 按百位匹配的状态码，5xx 记为 5
+}
+
+/**
+ * @function: parseRetryPolicy
+ * @desc: 解析逗号分隔的可重试条件
+ */
+func parseRetryPolicy(s string) (*retryPolicy, error) {
+	p := &retryPolicy{classes: map[string]bool{}, codes: map[int]bool{}, codeClasses: map[int]bool{}}
+	for _, cond := range strings.Split(s, ",") {
+		cond = strings.ToLower(strings.TrimSpace(cond))
+		switch {
+		case cond == "":
+		case cond == errorClassDNS, cond == errorClassTimeout, cond == errorClassConnectionRefused,
+			cond == errorClassConnectionReset, cond == errorClassOther:
+			p.classes[cond] = true
+		case len(cond) == 3 && strings.HasSuffix(cond, "xx") && cond[0] >= '1' && cond[0] <= '5':
+			p.codeClasses[int(cond[0]-'0')] = true
+		default:
+			code, err := strconv.Atoi(cond)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid retryable condition %q", cond)
+			}
+			p.codes[code] = true
+		}
+	}
+	return p, nil
+}
+
+/**
+ * @function: retryable
+ * @desc: 根据请求错误或响应状态码判断是否需要重试
+ */
+func (p *retryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return p.classes[classifyError(err)]
+	}
+	return p.codes[resp.StatusCode] || p.codeClasses[resp.StatusCode/100]
+}
+
+/**
+ * @function: probeHTTP
+ * @desc: 发起健康检查请求，命中可重试条件时按 --probe.retry-backoff 退避后重试，最多尝试 --probe.retries 次，
+ *        返回成功的那次尝试或最后一次尝试的响应和耗时。
+ *        timeout 限制每一次尝试（包括读取响应体），而不是所有尝试的总耗时。
+ *        header 中的 Host 请求头覆盖请求的 Host
+ */
+func (c *Metrics) probeHTTP(ctx context.Context, method, url string, header http.Header, timeout time.Duration) (*http.Response, time.Duration, error) {
+	for attempt := 1; ; attempt++ {
+		if count, ok := ctx.Value(redirectCountKey{}).(*int); ok {
+			// 只统计最后一次尝试经过的重定向
+			*count = 0
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		req, err := http.NewRequestWithContext(attemptCtx, method, url, nil)
+		if err != nil {
+			cancel()
+			return nil, 0, err
+		}
+		req.Header = header.Clone()
+		if host := header.Get("Host"); host != "" {
+			req.Host = host
+		}
+		start := time.Now()
+		resp, err := c.httpClient.Do(req)
+		duration := time.Since(start)
+		wait := c.retryBackoff(attempt)
+		// 抓取的剩余时间不够退避时不再重试
+		if attempt >= c.cfg.ProbeRetries || !c.retryPolicy.retryable(resp, err) || !canRetry(ctx, wait) {
+			if resp == nil {
+				cancel()
+				return resp, duration, err
+			}
+			// 响应体读完关闭时才释放超时
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, duration, err
+		}
+		if resp != nil {
+			drainBody(resp)
+		}
+		cancel()
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, duration, err
+		}
+	}
+}
+
+// 第 attempt 次尝试失败后的退避时间，每次翻倍
+func (c *Metrics) retryBackoff(attempt int) time.Duration {
+	return c.cfg.ProbeRetryBackoff << (attempt - 1)
+}
+
+// 退避结束时抓取没有超时才值得重试
+func canRetry(ctx context.Context, wait time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > wait
+}
+
+// 等待退避时间，抓取超时后立即返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 关闭响应体时同时释放请求的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// 丢弃响应体时最多读取的字节数，超过后直接关闭连接
+const maxDrainBytes = 64 << 10
+
+/**
+ * @function: drainBody
+ * @desc: 读完（有上限）并关闭响应体，使连接可以被复用。
+ *        探测超时覆盖了读取响应体的时间，响应体很大或者发送很慢时读取会在探测超时到达时中断，
+ *        不会长时间占用并发探测的名额
+ */
+func drainBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	resp.Body.Close()
+}
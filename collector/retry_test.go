@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 响应体发送很慢时，读取响应体在探测超时到达时中断，并发名额可以继续用于下一个目标
+func TestSlowBodyReleasesWorker(t *testing.T) {
+	ip, port := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	cfg := DefaultConfig()
+	cfg.ProbeTimeout = 200 * time.Millisecond
+	cfg.ProbeConcurrency = 1
+	c := newTestMetrics(t, cfg, newTestPod("a", ip, port), newTestPod("b", ip, port), newTestPod("c", ip, port))
+
+	start := time.Now()
+	metrics := collectMetrics(c)
+	// 同一时间只有一个探测，每个目标最多占用一个探测超时
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("scrape took %s, slow bodies held the worker past the probe timeout", elapsed)
+	}
+	if got := samples(t, metrics, "container_health_check_duration_millisecond"); len(got) != 3 {
+		t.Fatalf("got %d container_health_check_duration_millisecond samples, want 3", len(got))
+	}
+}
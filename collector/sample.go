@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"hash/fnv"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/**
+ * @function: sampled
+ * @desc: 按 pod UID 的哈希决定是否探测该 pod。同一个 pod 在每次抓取中的结果都相同，
+ *        因此样本在 pod 的生命周期内保持稳定，只有新建的 pod 才会重新参与抽样
+ */
+func sampled(uid types.UID, fraction float64) bool {
+	if fraction >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(uid))
+	return float64(h.Sum32())/(1<<32) < fraction
+}
@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+/**
+ * @function: targetState
+ * @desc: 单个探测目标（pod 中的某个容器）跨多次抓取保留的状态
+ */
+type targetState struct {
+	seen     int  // 已完成的探测次数
+	failures int  // 连续失败次数
+	ready    bool // 是否已经探测成功过
+	healthy  bool // 最近一次探测是否成功
+
+	labelValues []string // namespace、container_name、pod_name，用于输出状态切换次数
+	upCount     float64  // 由失败切换为成功的次数
+	downCount   float64  // 由成功切换为失败的次数
+	failCount   float64  // 累计失败次数
+
+	// 成功探测耗时的直方图，每次抓取每个容器贡献一次观测
+	durationBuckets []uint64 // 各区间的累计观测数，与 --histogram.buckets 一一对应
+	durationCount   uint64
+	durationSum     float64
+}
+
+/**
+ * @function: stateStore
+ * @desc: 探测目标状态表，健康检查 goroutine 并发读写，需要单独加锁
+ */
+type stateStore struct {
+	mu        sync.Mutex
+	targets   map[string]*targetState
+	startedAt time.Time
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{targets: map[string]*targetState{}, startedAt: time.Now()}
+}
+
+// 探测目标的唯一标识：pod UID + 容器名，pod 重建后 UID 变化会被视为新目标
+func targetKey(uid types.UID, containerName string) string {
+	return string(uid) + "/" + containerName
+}
+
+/**
+ * @function: observe
+ * @desc: 记录一次探测结果，返回更新后的状态副本。
+ *        新目标在 graceFailures 次以内连续失败时处于宽限期，结果不上报，也不计入失败次数和状态切换次数，
+ *        与此时不输出探测结果保持一致
+ */
+func (s *stateStore) observe(key string, labelValues []string, ok bool, graceFailures int) targetState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, exists := s.targets[key]
+	if !exists {
+		st = &targetState{}
+		s.targets[key] = st
+	}
+	st.seen++
+	if ok {
+		st.failures = 0
+	} else {
+		st.failures++
+	}
+	if st.inGrace(graceFailures) {
+		return *st
+	}
+
+	// labelValues 为空说明目标还没有上报过结果，第一次上报不算状态切换
+	if st.labelValues != nil && st.healthy != ok {
+		if ok {
+			st.upCount++
+		} else {
+			st.downCount++
+		}
+	}
+	st.healthy = ok
+	st.labelValues = labelValues
+	if !ok {
+		st.failCount++
+	}
+	return *st
+}
+
+// 目标是否还处于新目标的宽限期：从发现起一直失败，且失败次数没有超过 graceFailures
+func (st targetState) inGrace(graceFailures int) bool {
+	return st.failures > 0 && st.failures == st.seen && st.seen <= graceFailures
+}
+
+/**
+ * @function: markReady
+ * @desc: 记录目标首次探测成功，返回从 pod 创建到首次成功的耗时；
+ *        只对 exporter 启动之后创建的 pod 返回 true，之前就存在的 pod 无法知道真实的就绪时间
+ */
+func (s *stateStore) markReady(key string, created time.Time) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, exists := s.targets[key]
+	if !exists || st.ready {
+		return 0, false
+	}
+	st.ready = true
+	if created.Before(s.startedAt) {
+		return 0, false
+	}
+	return time.Since(created), true
+}
+
+/**
+ * @function: observeDuration
+ * @desc: 把一次成功探测的耗时（秒）计入目标的直方图，目标需要先经过 observe 记录
+ */
+func (s *stateStore) observeDuration(key string, seconds float64, buckets []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, exists := s.targets[key]
+	if !exists {
+		return
+	}
+	if st.durationBuckets == nil {
+		st.durationBuckets = make([]uint64, len(buckets))
+	}
+	for i, upper := range buckets {
+		if seconds <= upper {
+			st.durationBuckets[i]++
+		}
+	}
+	st.durationCount++
+	st.durationSum += seconds
+}
+
+/**
+ * @function: collectHistograms
+ * @desc: 输出每个目标的探测耗时直方图，还没有成功探测过的目标不输出
+ */
+func (s *stateStore) collectHistograms(ch chan<- prometheus.Metric, desc *prometheus.Desc, buckets []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.targets {
+		if st.labelValues == nil || st.durationBuckets == nil {
+			continue
+		}
+		counts := make(map[float64]uint64, len(buckets))
+		for i, upper := range buckets {
+			counts[upper] = st.durationBuckets[i]
+		}
+		ch <- prometheus.MustNewConstHistogram(desc, st.durationCount, st.durationSum, counts, st.labelValues...)
+	}
+}
+
+/**
+ * @function: collectCounters
+ * @desc: 输出每个目标的累计失败次数，以及在成功与失败之间切换的次数，频繁切换说明目标不稳定。
+ *        计数保存在状态表中，目标被清理后对应的序列也随之消失
+ */
+func (s *stateStore) collectCounters(ch chan<- prometheus.Metric, failuresDesc, transitionsDesc *prometheus.Desc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.targets {
+		if st.labelValues == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(failuresDesc, prometheus.CounterValue, st.failCount, st.labelValues...)
+		ch <- prometheus.MustNewConstMetric(transitionsDesc, prometheus.CounterValue, st.upCount, append(st.labelValues, "up")...)
+		ch <- prometheus.MustNewConstMetric(transitionsDesc, prometheus.CounterValue, st.downCount, append(st.labelValues, "down")...)
+	}
+}
+
+/**
+ * @function: reap
+ * @desc: 清理本次抓取中已不存在的探测目标，避免状态表随 pod 变更无限增长
+ */
+func (s *stateStore) reap(alive map[string]struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.targets {
+		if _, ok := alive[key]; !ok {
+			delete(s.targets, key)
+		}
+	}
+}
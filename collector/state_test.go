@@ -0,0 +1,41 @@
+package collector
+
+import "testing"
+
+// 宽限期内的失败不上报，失败次数和状态切换次数也不能增加
+func TestObserveGraceFailures(t *testing.T) {
+	s := newStateStore()
+	labels := []string{"default", "app", "a"}
+	steps := []struct {
+		ok        bool
+		wantGrace bool
+		wantFail  float64
+		wantUp    float64
+		wantDown  float64
+	}{
+		{false, true, 0, 0, 0},
+		{false, true, 0, 0, 0},
+		{false, false, 1, 0, 0},
+		{true, false, 1, 1, 0},
+		{false, false, 2, 1, 1},
+	}
+	for i, step := range steps {
+		st := s.observe("a/app", labels, step.ok, 2)
+		if got := st.inGrace(2); got != step.wantGrace {
+			t.Errorf("step %d: inGrace = %v, want %v", i, got, step.wantGrace)
+		}
+		if st.failCount != step.wantFail || st.upCount != step.wantUp || st.downCount != step.wantDown {
+			t.Errorf("step %d: failures=%v up=%v down=%v, want %v %v %v", i, st.failCount, st.upCount, st.downCount, step.wantFail, step.wantUp, step.wantDown)
+		}
+	}
+}
+
+// 宽限期内恢复的目标第一次上报就是成功，不算状态切换
+func TestObserveRecoveredDuringGrace(t *testing.T) {
+	s := newStateStore()
+	s.observe("a/app", []string{"default", "app", "a"}, false, 2)
+	st := s.observe("a/app", []string{"default", "app", "a"}, true, 2)
+	if st.failCount != 0 || st.upCount != 0 {
+		t.Errorf("failures=%v up=%v, want 0 0", st.failCount, st.upCount)
+	}
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var statusTemplate = template.Must(template.New("status").Parse(`<html>
+    <head>
+    <title>Health Check Status</title>
+    <meta http-equiv="refresh" content="10">
+    </head>
+    <body>
+    <h1>Health Check Status</h1>
+    {{if .Rows}}
+    <p>Last scrape: {{.UpdatedAt.Format "2006-01-02 15:04:05"}}, {{len .Rows}} targets</p>
+    <table border="1" cellpadding="4" cellspacing="0">
+    <tr><th>Namespace</th><th>Pod</th><th>Container</th><th>URL</th><th>Result</th><th>Latency</th><th>Status</th></tr>
+    {{range .Rows}}
+    <tr><td>{{.Namespace}}</td><td>{{.Pod}}</td><td>{{.Container}}</td><td>{{.URL}}</td><td>{{.Result}}</td><td>{{.Latency}}</td><td>{{.Status}}</td></tr>
+    {{end}}
+    </table>
+    {{else}}
+    <p>No health check results yet. Results appear after the first scrape of the metrics endpoint.</p>
+    {{end}}
+    </body>
+    </html>`))
+
+// 状态页中的一行
+type statusRow struct {
+	Namespace string
+	Pod       string
+	Container string
+	URL       string
+	Result    string
+	Latency   string
+	Status    string
+}
+
+/**
+ * @function: storeResults
+ * @desc: 保存最近一次抓取的探测结果，供状态页展示
+ */
+func (c *Metrics) storeResults(results []*probeResult) {
+	c.resultsMu.Lock()
+	defer c.resultsMu.Unlock()
+	c.lastResults = results
+	c.lastResultsAt = time.Now()
+}
+
+/**
+ * @function: StatusHandler
+ * @desc: 以 HTML 表格展示最近一次抓取中每个目标的探测结果，页面每 10 秒自动刷新
+ */
+func (c *Metrics) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	c.resultsMu.RLock()
+	results, updatedAt := c.lastResults, c.lastResultsAt
+	c.resultsMu.RUnlock()
+
+	rows := make([]statusRow, 0, len(results))
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		row := statusRow{
+			Namespace: res.pod.Namespace,
+			Pod:       res.pod.Name,
+			Container: res.containerName,
+			URL:       res.url,
+			Result:    "OK",
+			Latency:   res.duration.Round(time.Millisecond).String(),
+			Status:    "-",
+		}
+		if res.err != nil {
+			row.Result = "FAIL: " + res.err.Error()
+			row.Latency = "-"
+		}
+		if res.statusCode != 0 {
+			row.Status = strconv.Itoa(res.statusCode) + " " + http.StatusText(res.statusCode)
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Namespace != rows[j].Namespace {
+			return rows[i].Namespace < rows[j].Namespace
+		}
+		return rows[i].Pod < rows[j].Pod
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := statusTemplate.Execute(w, struct {
+		UpdatedAt time.Time
+		Rows      []statusRow
+	}{updatedAt, rows})
+	if err != nil {
+		slog.Error("Failed to render status page", "err", err)
+	}
+}
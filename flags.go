@@ -0,0 +1,170 @@
+package main
+
+import (
+	"exporters/collector"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// collector 的配置，默认值来自 collector.DefaultConfig
+var collectorConfig = collector.DefaultConfig()
+
+func init() {
+	cfg := &collectorConfig
+
+	// Kubernetes 连接与 pod 列表
+	flag.StringVar(&cfg.Kubeconfig, "kubeconfig", cfg.Kubeconfig, "Absolute path to the kubeconfig file, used when not running inside a cluster.")
+	flag.Var((*namespaceList)(&cfg.Namespaces), "namespace", "Namespace to scrape; repeatable or comma-separated. Pods are listed per namespace instead of cluster-wide, so the service account only needs access to these namespaces. Empty scrapes all namespaces.")
+	flag.StringVar(&cfg.PodSelector, "pod.selector", cfg.PodSelector, "Label selector restricting the pods to probe, e.g. monitoring=true. Applied server-side when listing pods; empty selects all pods.")
+	flag.BoolVar(&cfg.PodWatch, "pod.watch", cfg.PodWatch, "Keep a local pod cache updated through a watch (shared informer) and read it on every scrape instead of listing pods from the API server each time. Requires list and watch permissions on pods; disabled by default, in which case pods are listed on every scrape.")
+	flag.DurationVar(&cfg.PodSyncTimeout, "pod.sync-timeout", cfg.PodSyncTimeout, "Maximum time to wait for the pod cache to sync at startup when --pod.watch is enabled. The exporter exits with an error when the cache does not sync in time, e.g. because the service account may not watch pods (0 waits forever).")
+	flag.BoolVar(&cfg.FailScrapeOnListError, "fail-scrape-on-list-error", cfg.FailScrapeOnListError, "Fail the whole scrape with HTTP 500 when pods cannot be listed from the Kubernetes API, instead of returning empty metrics.")
+
+	// 探测目标的选择
+	flag.BoolVar(&cfg.IncludeNotReady, "include-not-ready", cfg.IncludeNotReady, "Also probe pods that are not in the Running phase (Pending, Succeeded, Failed) as long as they have a pod IP.")
+	flag.BoolVar(&cfg.ProbeTerminating, "probe-terminating", cfg.ProbeTerminating, "Keep probing pods that are being deleted until they disappear, labelled with terminating=\"1\", to observe graceful shutdown behavior.")
+	flag.Float64Var(&cfg.ProbeSampleFraction, "probe-sample-fraction", cfg.ProbeSampleFraction, "Fraction of pods to probe, between 0 and 1. Pods are chosen by a hash of their UID, so the same pods stay in the sample across scrapes.")
+	flag.StringVar(&cfg.ProbeIPFamily, "probe-ip-family", cfg.ProbeIPFamily, "IP family used to probe dual-stack pods: any, ipv4 or ipv6. Pods without an address of the requested family are skipped.")
+	flag.IntVar(&cfg.NewTargetGraceFailures, "new-target-grace-failures", cfg.NewTargetGraceFailures, "Number of consecutive failures tolerated before a newly discovered target is reported as down (0 reports immediately).")
+
+	// 探测行为
+	// 探针配置了更小的 timeoutSeconds 时以探针为准
+	flag.DurationVar(&cfg.ProbeTimeout, "probe.timeout", cfg.ProbeTimeout, "Upper bound of a single health check attempt, including connecting and reading the response. A smaller timeoutSeconds set on the liveness probe takes precedence.")
+	// 兼容旧版本的参数名
+	flag.DurationVar(&cfg.ProbeTimeout, "probe-timeout", cfg.ProbeTimeout, "Deprecated: use --probe.timeout.")
+	flag.DurationVar(&cfg.ProbeConnectTimeout, "probe-connect-timeout", cfg.ProbeConnectTimeout, "Timeout for establishing the connection of a health check, to fail fast on dead endpoints (0 means only --probe.timeout applies).")
+	flag.DurationVar(&cfg.ScrapeTimeout, "scrape.timeout", cfg.ScrapeTimeout, "Deadline of a whole scrape, including listing pods and all health checks. Health checks still running when it expires are aborted and reported as failed; keep it below the scrape_timeout of Prometheus (0 disables the deadline).")
+	flag.IntVar(&cfg.ProbeConcurrency, "probe.concurrency", cfg.ProbeConcurrency, "Number of workers running health checks concurrently during a scrape (0 means one goroutine per target). Upper bound of the auto-tuned concurrency when --target-scrape-duration is set.")
+	// 兼容旧版本的参数名
+	flag.IntVar(&cfg.ProbeConcurrency, "max-concurrency", cfg.ProbeConcurrency, "Deprecated: use --probe.concurrency.")
+	flag.IntVar(&cfg.MinConcurrency, "min-concurrency", cfg.MinConcurrency, "Lower bound of the auto-tuned concurrency when --target-scrape-duration is set.")
+	flag.DurationVar(&cfg.TargetScrapeDuration, "target-scrape-duration", cfg.TargetScrapeDuration, "Automatically tune the number of concurrent health checks between --min-concurrency and --probe.concurrency to keep the scrape duration under this target (0 disables auto-tuning).")
+	flag.IntVar(&cfg.ProbeRetries, "probe.retries", cfg.ProbeRetries, "Maximum number of attempts per health check when the failure matches --retryable-conditions (1 disables retries).")
+	flag.DurationVar(&cfg.ProbeRetryBackoff, "probe.retry-backoff", cfg.ProbeRetryBackoff, "Wait before retrying a failed health check, doubled after every attempt. A retry that would not finish before --scrape.timeout is not attempted.")
+	flag.StringVar(&cfg.RetryableConditions, "retryable-conditions", cfg.RetryableConditions, "Comma-separated failures that are retried: error classes (dns, timeout, connection_refused, connection_reset, other) and response status codes such as 503 or 5xx.")
+	flag.StringVar(&cfg.ProbeSourceIP, "probe-source-ip", cfg.ProbeSourceIP, "Local IP address health check connections originate from, for exporters running on multi-homed nodes.")
+	flag.BoolVar(&cfg.ProbeInsecureSkipVerify, "probe.insecure-skip-verify", cfg.ProbeInsecureSkipVerify, "Do not verify the certificate of HTTPS health checks, which pods usually serve self-signed or issued for names other than the pod IP. Like the kubelet, the probe then only checks the endpoint answers.")
+	flag.BoolVar(&cfg.ProbePreferHead, "probe-prefer-head", cfg.ProbePreferHead, "Send health checks as HEAD requests, falling back to GET when the endpoint answers 405, and add the method used as method label.")
+	flag.StringVar(&cfg.ProbePathTemplate, "probe-path-template", cfg.ProbePathTemplate, "Template overriding the probe path, with {namespace}, {pod}, {container}, {label:<key>} and {annotation:<key>} placeholders. Falls back to the path of the probe when a placeholder cannot be resolved.")
+	flag.StringVar(&cfg.ProbeQuery, "probe-query", cfg.ProbeQuery, "Query parameters appended to the probe path, e.g. deep=true&verbose=1.")
+	flag.BoolVar(&cfg.ProbeClockSkew, "probe-clock-skew", cfg.ProbeClockSkew, "Compare the Date header of health check responses with the local time and export the skew per pod.")
+	flag.BoolVar(&cfg.ProbeCountRedirects, "probe-count-redirects", cfg.ProbeCountRedirects, "Export the number of redirects followed by each health check as container_health_check_redirect_count.")
+
+	// 指标输出
+	flag.StringVar(&cfg.MetricNamespace, "metric.namespace", cfg.MetricNamespace, "Prefix prepended to the names of all health check metrics, e.g. myteam turns container_health_check_up into myteam_container_health_check_up.")
+	flag.StringVar(&cfg.MetricTimestamp, "metric-timestamp", cfg.MetricTimestamp, "Timestamp policy of the container_health_check_duration_millisecond samples: scrape (no explicit timestamp, Prometheus assigns the scrape time) or probe (time the health check finished). Other metrics never carry a timestamp.")
+	// 兼容旧版本的参数名，与 --metric-timestamp 设置同一个值，命令行中后出现的为准
+	flag.Var((*honorTimestamps)(&cfg.MetricTimestamp), "metric.honor-timestamps", "Deprecated: use --metric-timestamp=probe. Sets the same policy as --metric-timestamp (false means scrape); when both are given the one appearing last on the command line wins.")
+	flag.StringVar(&cfg.Aggregate, "aggregate", cfg.Aggregate, "Aggregation level of the emitted health check metrics: pod or workload. workload collapses pods into one series per owning workload and loses per-pod granularity.")
+	flag.StringVar(&cfg.InstanceLabel, "instance-label", cfg.InstanceLabel, "Value of the replica label attached to all emitted metrics, defaults to the POD_NAME environment variable. Leave both empty to omit the label.")
+	flag.BoolVar(&cfg.ImageTagLabel, "image-tag-label", cfg.ImageTagLabel, "Add the container image tag (or short digest for digest-pinned images) as image_tag label, to compare health checks across versions during rollouts.")
+	flag.BoolVar(&cfg.PortLabel, "port-label", cfg.PortLabel, "Add the probed port as port label, to tell apart health checks of different ports on the same container.")
+	flag.BoolVar(&cfg.AppLabel, "app-label", cfg.AppLabel, "Add the app label of the pod as app label, for dashboards that relied on older versions reporting it as container_name.")
+	flag.IntVar(&cfg.MaxLabelLength, "max-label-length", cfg.MaxLabelLength, "Maximum length of label values taken from pod labels, images and other dynamic sources; longer values are truncated (0 disables truncation).")
+	flag.BoolVar(&cfg.LatencyOnFailure, "latency-on-failure", cfg.LatencyOnFailure, "Emit -1 as the health check duration when the check fails, like older versions did. By default failures are only reported through container_health_check_up and container_health_check_failures_total.")
+	flag.BoolVar(&cfg.DurationGauge, "emit-duration-gauge", cfg.DurationGauge, "Export the duration of the latest health check as the container_health_check_duration_millisecond gauge. Disable to rely on the histogram of --histogram.buckets only.")
+	flag.Var((*bucketList)(&cfg.HistogramBuckets), "histogram.buckets", "Comma-separated upper bounds in seconds of the container_health_check_duration_seconds histogram, e.g. 0.005,0.01,0.05,0.1,0.5,1. Each scrape adds one observation per container. Empty disables the histogram.")
+	flag.BoolVar(&cfg.EmitSummary, "emit-summary", cfg.EmitSummary, "Export container_health_check_summary, carrying the latest health check result, restart count and pod phase of each container in a single series.")
+	flag.DurationVar(&cfg.CacheTTL, "cache.ttl", cfg.CacheTTL, "Serve the metrics of the previous scrape instead of probing again when scraped within this interval, to decouple the probe rate from the scrape rate (0 disables the cache).")
+
+	// 日志
+	flag.BoolVar(&cfg.LogResults, "log-results", cfg.LogResults, "Log every health check result to stdout, using the format and level of --log.format and --log.level.")
+	flag.Float64Var(&cfg.LogResultsSample, "log-results-sample", cfg.LogResultsSample, "Fraction of health check results written when --log-results is enabled, between 0 and 1.")
+	flag.BoolVar(&cfg.LogScrapeSummary, "log-scrape-summary", cfg.LogScrapeSummary, "Log a one-line summary at the end of every scrape: pods listed, probed, succeeded, failed, skipped by reason and duration.")
+}
+
+/**
+ * @function: namespaceList
+ * @desc: --namespace 参数，可以重复指定，也可以用逗号分隔，重复的命名空间只保留一个
+ */
+type namespaceList []string
+
+func (l *namespaceList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *namespaceList) Set(value string) error {
+	for _, ns := range strings.Split(value, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || l.contains(ns) {
+			continue
+		}
+		*l = append(*l, ns)
+	}
+	return nil
+}
+
+func (l namespaceList) contains(ns string) bool {
+	for _, v := range l {
+		if v == ns {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @function: bucketList
+ * @desc: --histogram.buckets 参数，逗号分隔的区间上限（秒），按从小到大排序并去重
+ */
+type bucketList []float64
+
+func (l *bucketList) String() string {
+	values := make([]string, len(*l))
+	for i, v := range *l {
+		values[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *bucketList) Set(value string) error {
+	var buckets []float64
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid histogram bucket %q, expected a positive number of seconds", s)
+		}
+		buckets = append(buckets, v)
+	}
+	sort.Float64s(buckets)
+	*l = nil
+	for _, v := range buckets {
+		if n := len(*l); n == 0 || (*l)[n-1] != v {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+/**
+ * @function: honorTimestamps
+ * @desc: --metric.honor-timestamps 参数，作为布尔参数解析，true 等价于 --metric-timestamp=probe，false 等价于 scrape
+ */
+type honorTimestamps string
+
+func (p *honorTimestamps) String() string {
+	return strconv.FormatBool(*p == "probe")
+}
+
+func (p *honorTimestamps) Set(value string) error {
+	honor, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*p = "scrape"
+	if honor {
+		*p = "probe"
+	}
+	return nil
+}
+
+func (p *honorTimestamps) IsBoolFlag() bool {
+	return true
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestHonorTimestampsAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default", nil, "scrape"},
+		{"alias", []string{"--metric.honor-timestamps"}, "probe"},
+		{"alias disabled", []string{"--metric.honor-timestamps=false"}, "scrape"},
+		{"alias last", []string{"--metric-timestamp=scrape", "--metric.honor-timestamps"}, "probe"},
+		{"policy last", []string{"--metric.honor-timestamps", "--metric-timestamp=scrape"}, "scrape"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := "scrape"
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.StringVar(&policy, "metric-timestamp", policy, "")
+			fs.Var((*honorTimestamps)(&policy), "metric.honor-timestamps", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if policy != tt.want {
+				t.Errorf("metric timestamp policy = %q, want %q", policy, tt.want)
+			}
+		})
+	}
+}
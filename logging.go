@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+var (
+	logLevel  = flag.String("log.level", "info", "Only log messages with the given severity or above: debug, info, warn or error.")
+	logFormat = flag.String("log.format", "text", "Output format of log messages: text (logfmt) or json.")
+)
+
+/**
+ * @function: setupLogger
+ * @desc: 按 --log.level 和 --log.format 设置全局的结构化日志，exporter 和 collector 的日志都输出到 stderr，
+ *        标准库 log 包的输出也会转到该 logger；--log-results 的探测结果使用相同的格式和级别输出到 stdout
+ */
+func setupLogger() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return fmt.Errorf("invalid --log.level %q: %w", *logLevel, err)
+	}
+
+	if *logFormat != "text" && *logFormat != "json" {
+		return fmt.Errorf("invalid --log.format %q: must be text or json", *logFormat)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	newHandler := func(w io.Writer) slog.Handler {
+		if *logFormat == "json" {
+			return slog.NewJSONHandler(w, opts)
+		}
+		return slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(newHandler(os.Stderr)))
+	collectorConfig.ResultLogger = slog.New(newHandler(os.Stdout))
+	return nil
+}
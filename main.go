@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"exporters/collector"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	// "github.com/w0nwig/health-check-exporter/collector"
 
@@ -14,20 +22,72 @@ import (
 
 var (
 	// 命令行参数
-	listenAddr  = flag.String("web.listen-port", "8089", "An port to listen on for web interface and telemetry.")
+	listenAddress = flag.String("web.listen-address", ":8089", "Address (host:port) to listen on for web interface and telemetry, e.g. 127.0.0.1:8089 to bind a single interface.")
+	// 兼容旧版本，只写端口时监听所有网卡
+	listenPort  = flag.String("web.listen-port", "", "Deprecated: use --web.listen-address. Port to listen on, on all interfaces; overrides --web.listen-address when set.")
 	metricsPath = flag.String("web.telemetry-path", "/metrics", "A path under which to expose metrics.")
+	// 没有认证保护时 /config 会暴露部署细节，默认关闭
+	enableConfig = flag.Bool("web.enable-config", false, "Expose the effective flag values as JSON under /config, with sensitive values redacted.")
+	// 状态页展示所有 pod 的探测结果，默认关闭
+	enableStatusUI = flag.Bool("enable-status-ui", false, "Serve a /status HTML page listing the latest health check result of every target.")
+	// 收到 SIGTERM 后等待正在进行的抓取完成的时间，应小于 pod 的 terminationGracePeriodSeconds
+	shutdownTimeout = flag.Duration("web.shutdown-timeout", 20*time.Second, "Time to wait for in-flight requests such as /metrics scrapes to complete after SIGINT or SIGTERM before exiting.")
 )
 
+// 日志中展示的访问地址，没有指定 host 时使用 localhost
+func displayHost(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
 func main() {
 	flag.Parse()
+	if err := setupLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	useTLS, err := tlsEnabled()
+	if err != nil {
+		slog.Error("Invalid TLS configuration", "err", err)
+		os.Exit(1)
+	}
+	var auth basicAuth
+	if *authFile != "" {
+		if auth, err = loadAuthFile(*authFile); err != nil {
+			slog.Error("Failed to load --web.auth-file", "err", err)
+			os.Exit(1)
+		}
+	}
 	// collector.NewMetrics().Collect()
-	metrics := collector.NewMetrics()
+	metrics, err := collector.NewMetrics(collectorConfig)
+	if err != nil {
+		slog.Error("Failed to initialize the collector", "err", err)
+		os.Exit(1)
+	}
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(metrics)
+	registry.MustRegister(metrics, newBuildInfo())
+
+	if *pushgatewayURL != "" {
+		startPusher(registry)
+	}
+
+	// 使用独立的 ServeMux，避免引入的依赖在默认 mux 上注册的 handler（例如 pprof）被意外暴露
+	mux := http.NewServeMux()
+	mux.Handle(*metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 
-	http.Handle(*metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	if *enableConfig {
+		mux.HandleFunc("/config", configHandler)
+	}
+	if *enableStatusUI {
+		mux.HandleFunc("/status", metrics.StatusHandler)
+	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/healthz", metrics.HealthzHandler)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
             <head><title>A Prometheus Exporter</title></head>
             <body>
@@ -37,6 +97,43 @@ func main() {
             </html>`))
 	})
 
-	log.Printf("Starting Server at http://localhost:%s%s", *listenAddr, *metricsPath)
-	log.Fatal(http.ListenAndServe(":"+*listenAddr, nil))
+	var handler http.Handler = mux
+	if auth != nil {
+		handler = auth.wrap(mux)
+	}
+	addr := *listenAddress
+	if *listenPort != "" {
+		slog.Warn("--web.listen-port is deprecated, use --web.listen-address instead")
+		addr = ":" + *listenPort
+	}
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		scheme := "http"
+		if useTLS {
+			scheme = "https"
+		}
+		slog.Info("Starting server", "address", addr, "url", scheme+"://"+displayHost(addr)+*metricsPath, "basic_auth", auth != nil)
+		var err error
+		if useTLS {
+			err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Kubernetes 删除 pod 时发送 SIGTERM，先停止接受新连接，等待正在进行的抓取返回后再退出
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-stop
+	slog.Info("Shutting down", "signal", sig.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Graceful shutdown did not complete", "err", err)
+	}
 }
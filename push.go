@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+var (
+	// Pushgateway 相关命令行参数，未设置 url 时不启用推送
+	pushgatewayURL         = flag.String("pushgateway-url", "", "Pushgateway URL to push collected metrics to, in addition to serving them for scraping.")
+	pushgatewayJob         = flag.String("pushgateway-job", "health_check_exporter", "Job name used when pushing to the Pushgateway.")
+	pushgatewayInterval    = flag.Duration("pushgateway-interval", 30*time.Second, "Interval between two pushes to the Pushgateway, also the timeout of a single push request.")
+	pushgatewayGroupingKey = flag.String("pushgateway-grouping-key", "", "Additional grouping key for the Pushgateway, as comma-separated name=value pairs.")
+)
+
+/**
+ * @function: parseGroupingKey
+ * @desc: 解析 name=value,name=value 形式的分组键
+ */
+func parseGroupingKey(s string) (map[string]string, error) {
+	grouping := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid pushgateway grouping key %q, expected name=value", pair)
+		}
+		grouping[name] = value
+	}
+	return grouping, nil
+}
+
+/**
+ * @function: startPusher
+ * @desc: 按固定间隔把 registry 中的指标推送到 Pushgateway，推送失败只计数不退出。
+ *        请求的超时不超过推送间隔，Pushgateway 无响应时不会让推送 goroutine 一直阻塞
+ */
+func startPusher(registry *prometheus.Registry) {
+	grouping, err := parseGroupingKey(*pushgatewayGroupingKey)
+	if err != nil {
+		slog.Error("Invalid --pushgateway-grouping-key", "err", err)
+		os.Exit(1)
+	}
+
+	pushFailures := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "health_check_exporter_push_failures_total",
+		Help: "The number of failed pushes to the Pushgateway",
+	})
+	registry.MustRegister(pushFailures)
+
+	pusher := push.New(*pushgatewayURL, *pushgatewayJob).
+		Gatherer(registry).
+		Client(&http.Client{Timeout: *pushgatewayInterval})
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	go func() {
+		ticker := time.NewTicker(*pushgatewayInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := pusher.Push(); err != nil {
+				pushFailures.Inc()
+				slog.Warn("Failed to push metrics", "url", *pushgatewayURL, "err", err)
+			}
+		}
+	}()
+}
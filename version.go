@@ -0,0 +1,32 @@
+package main
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 构建时通过 -ldflags 注入，例如：
+// go build -ldflags "-X main.version=v1.2.0 -X main.revision=$(git rev-parse --short HEAD)"
+var (
+	version  = "dev"
+	revision = "unknown"
+)
+
+/**
+ * @function: newBuildInfo
+ * @desc: health_check_exporter_build_info 指标，值恒为 1，通过标签记录运行的版本
+ */
+func newBuildInfo() prometheus.Gauge {
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "health_check_exporter_build_info",
+		Help: "A metric with a constant '1' value labeled by version, revision and go_version from which the exporter was built",
+		ConstLabels: prometheus.Labels{
+			"version":    version,
+			"revision":   revision,
+			"go_version": runtime.Version(),
+		},
+	})
+	buildInfo.Set(1)
+	return buildInfo
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// 两者同时设置时通过 HTTPS 提供服务，否则保持明文 HTTP
+	tlsCert = flag.String("web.tls-cert", "", "Path to the TLS certificate (PEM) to serve HTTPS with. Requires --web.tls-key.")
+	tlsKey  = flag.String("web.tls-key", "", "Path to the TLS private key (PEM) to serve HTTPS with. Requires --web.tls-cert.")
+	// 每行一个 user:password，password 可以是明文或者 htpasswd -B 生成的 bcrypt 哈希
+	authFile = flag.String("web.auth-file", "", "Path to a file of user:password lines (htpasswd style, bcrypt hashes or plain passwords) required as basic auth by all endpoints except /healthz.")
+)
+
+// 校验 TLS 参数是否成对设置，返回是否启用 TLS
+func tlsEnabled() (bool, error) {
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return false, errors.New("--web.tls-cert and --web.tls-key must be set together")
+	}
+	return *tlsCert != "", nil
+}
+
+/**
+ * @function: basicAuth
+ * @desc: 用户名到密码（明文或 bcrypt 哈希）的映射
+ */
+type basicAuth map[string]string
+
+/**
+ * @function: loadAuthFile
+ * @desc: 读取 htpasswd 风格的认证文件，忽略空行和 # 开头的注释行
+ */
+func loadAuthFile(path string) (basicAuth, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := basicAuth{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		user, password, ok := strings.Cut(text, ":")
+		if !ok || user == "" || password == "" {
+			return nil, fmt.Errorf("%s:%d: expected user:password", path, line)
+		}
+		if err := checkPasswordFormat(password); err != nil {
+			return nil, fmt.Errorf("%s:%d: user %q: %w", path, line, user, err)
+		}
+		users[user] = password
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: no users defined", path)
+	}
+	return users, nil
+}
+
+/**
+ * @function: checkPasswordFormat
+ * @desc: 只支持 bcrypt 哈希和明文密码。htpasswd 默认生成的 $apr1$，以及 {SHA}、$5$、$6$ 等其他格式
+ *        如果被当作明文比较，真正的密码会被拒绝，哈希字符串本身反而可以登录，因此直接报错
+ */
+func checkPasswordFormat(password string) error {
+	if strings.HasPrefix(password, "$2") {
+		if _, err := bcrypt.Cost([]byte(password)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return nil
+	}
+	if strings.HasPrefix(password, "$") || strings.HasPrefix(password, "{SHA}") {
+		return errors.New("unsupported password hash, only bcrypt hashes (htpasswd -B) and plain passwords are supported")
+	}
+	return nil
+}
+
+// 校验用户名和密码
+func (a basicAuth) valid(user, password string) bool {
+	expected, ok := a[user]
+	if !ok {
+		return false
+	}
+	if strings.HasPrefix(expected, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(expected), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
+
+/**
+ * @function: wrap
+ * @desc: 要求 basic auth，认证失败返回 401。/healthz 供 kubelet 探测 exporter 自身，不要求认证
+ */
+func (a basicAuth) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			user, password, ok := r.BasicAuth()
+			if !ok || !a.valid(user, password) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="health-check-exporter"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAuthFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantErr bool
+	}{
+		{"plain", "admin:secret", false},
+		{"bcrypt", "admin:$2y$05$SnR0AsyV/ED2zpl4l5AsA.Si3TSnCHeH9DdVCNMrLrXqsBUxNHe9K", false},
+		{"apr1", "admin:$apr1$L0rD8Hnm$lwn7OTZTYcdJgZ0titimc/", true},
+		{"sha", "admin:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", true},
+		{"sha512 crypt", "admin:$6$saltsalt$qFmFH.bQmmtXzyBY0s9v7Oicd2z4XSIecDzlB5KiA2/jctKu9YterLp8wwnSq.qc.eoxqOmSuNp2xS0ktL3nh/", true},
+		{"truncated bcrypt", "admin:$2y$05$SnR0AsyV", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "auth")
+			if err := os.WriteFile(path, []byte(tt.line+"\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadAuthFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadAuthFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}